@@ -3,16 +3,30 @@ package perspectivefungo
 import (
 	"github.com/go-gl/mathgl/mgl32"
 	"math"
-	"time"
 )
 
 const (
-	ACCELERATION = 98.1
-	INCREMENT    = 0.02
+	ACCELERATION   = 98.1
+	INCREMENT      = 0.02
+	ROTATION_SPEED = math.Pi // Radians per second
+	MAX_REBOUND    = 0.5     // Highest a rebound can rise, in cells
+	CRUMBLE_TIME   = 0.3     // Seconds for a crumbling block to vanish
 )
 
+// Bounce describes how the ball rebounds when it lands on a block.
+type Bounce struct {
+	Restitution float64 // Fraction of the speed kept after each impact
+	Rebounds    int     // Number of visible rebounds before the ball settles
+}
+
+// BallBounce is used by games when the ball lands on a block, set to nil to stop the ball dead.
+var BallBounce = &Bounce{
+	Restitution: 0.3,
+	Rebounds:    2,
+}
+
 type Animation interface {
-	Tick() bool // Return true if animation has completed
+	Tick(float64) bool // Return true if animation has completed
 }
 
 var (
@@ -93,7 +107,7 @@ func NewRotateToAxisAnimation(rotation *mgl32.Mat4, cameraEye, cameraUp mgl32.Ve
 	return a
 }
 
-func (a *rotateToAxisAnimation) Tick() bool {
+func (a *rotateToAxisAnimation) Tick(now float64) bool {
 	// Camera Eye
 	a.vectors[a.closestAxisIndexEye] = a.rotation.Inv().Mul4x1(axes[a.closestAxisIndexEye]).Vec3().Mul(a.closestAxisSignEye).Normalize()
 
@@ -138,41 +152,117 @@ func (a *rotateToAxisAnimation) Tick() bool {
 	return true
 }
 
+type rotateAnimation struct {
+	rotation *mgl32.Mat4
+	target   mgl32.Mat4
+	from     mgl32.Quat
+	to       mgl32.Quat
+	duration float64
+	start    float64
+	started  bool
+}
+
+// NewRotateAnimation turns the rotation towards the target along the shortest arc.
+func NewRotateAnimation(rotation *mgl32.Mat4, target mgl32.Mat4) Animation {
+	from := mgl32.Mat4ToQuat(*rotation).Normalize()
+	to := mgl32.Mat4ToQuat(target).Normalize()
+	dot := from.Dot(to)
+	if dot < 0 {
+		// Both quaternions represent the same rotation, but only one avoids the long way round
+		to = to.Scale(-1)
+		dot = -dot
+	}
+	angle := 2 * math.Acos(math.Min(1, float64(dot)))
+	return &rotateAnimation{
+		rotation: rotation,
+		target:   target,
+		from:     from,
+		to:       to,
+		duration: angle / ROTATION_SPEED,
+	}
+}
+
+func (a *rotateAnimation) Tick(now float64) bool {
+	if !a.started {
+		a.start = now
+		a.started = true
+	}
+	if elapsed := now - a.start; elapsed < a.duration {
+		*a.rotation = mgl32.QuatSlerp(a.from, a.to, float32(elapsed/a.duration)).Mat4()
+		return false
+	}
+	*a.rotation = a.target
+	return true
+}
+
 type ReleaseBallAnimation interface {
 	Animation
 	Progress(float64) bool
 }
 
 type releaseBallAnimation struct {
-	size        uint
-	rotation    mgl32.Mat4
-	player      *[3]float32
-	initial     [3]float32
-	goal        [3]float32
-	blocks      [][3]float32
-	portals     [][3]float32
-	releaseAxis mgl32.Vec4
-	start       time.Time
+	player  *[3]float32
+	fall    *Fall
+	bounce  *Bounce
+	start   float64
+	started bool
 }
 
-func NewReleaseBallAnimation(size uint, rotation mgl32.Mat4, player *[3]float32, goal [3]float32, blocks, portals [][3]float32) ReleaseBallAnimation {
+func NewReleaseBallAnimation(player *[3]float32, fall *Fall) ReleaseBallAnimation {
 	return &releaseBallAnimation{
-		size:        size,
-		rotation:    rotation,
-		player:      player,
-		initial:     [3]float32{player[0], player[1], player[2]},
-		goal:        goal,
-		blocks:      blocks,
-		portals:     portals,
-		releaseAxis: rotation.Inv().Mul4x1(mgl32.Vec4{0, -1, 0, 1}),
+		player: player,
+		fall:   fall,
+	}
+}
+
+// NewBouncingBallAnimation is like NewReleaseBallAnimation, except a ball that lands on a block rebounds before settling in the same cell.
+func NewBouncingBallAnimation(player *[3]float32, fall *Fall, bounce *Bounce) ReleaseBallAnimation {
+	return &releaseBallAnimation{
+		player: player,
+		fall:   fall,
+		bounce: bounce,
+	}
+}
+
+type releaseBallsAnimation struct {
+	balls   []ReleaseBallAnimation
+	start   float64
+	started bool
+}
+
+// NewReleaseBallsAnimation moves every ball along its own part of the fall at the same time, and bounces them unless bounce is nil.
+func NewReleaseBallsAnimation(balls [][3]float32, fall *Fall, bounce *Bounce) ReleaseBallAnimation {
+	a := &releaseBallsAnimation{}
+	for _, f := range fall.falls() {
+		a.balls = append(a.balls, NewBouncingBallAnimation(&balls[f.Ball], f, bounce))
+	}
+	return a
+}
+
+func (a *releaseBallsAnimation) Tick(now float64) bool {
+	if !a.started {
+		a.start = now
+		a.started = true
+	}
+	return a.Progress(now - a.start)
+}
+
+func (a *releaseBallsAnimation) Progress(time float64) bool {
+	done := true
+	for _, b := range a.balls {
+		if !b.Progress(time) {
+			done = false
+		}
 	}
+	return done
 }
 
-func (a *releaseBallAnimation) Tick() bool {
-	if a.start.IsZero() {
-		a.start = time.Now()
+func (a *releaseBallAnimation) Tick(now float64) bool {
+	if !a.started {
+		a.start = now
+		a.started = true
 	}
-	return a.Progress(time.Now().Sub(a.start).Seconds())
+	return a.Progress(now - a.start)
 }
 
 func (a *releaseBallAnimation) Progress(time float64) bool {
@@ -186,86 +276,62 @@ func (a *releaseBallAnimation) Progress(time float64) bool {
 	distance := (0 * time) + (0.5 * ACCELERATION * time * time)
 	// fmt.Println("Distance:", distance)
 
-	for j := 0; j < 3; j++ {
-		a.player[j] = a.initial[j] + float32(distance)*a.releaseAxis[j]
+	// Follow the path until the remaining distance is less than a cell
+	path := a.fall.Path
+	step := 0
+	for ; step < len(path)-1; step++ {
+		if path[step+1].Portal {
+			// Moving through a portal is instantaneous
+			continue
+		}
+		if distance < 1 {
+			break
+		}
+		distance--
 	}
-	// fmt.Println("Player", a.player)
 
-	var (
-		steps = 1 + uint(distance)
-		step  uint
-		limit = a.size * 10 // 10x size so player is offscreen, well out of bounds
-		pos   [3]float32
-		cell  [3]int
-		next  [3]float32
-	)
+	if step == len(path)-1 {
+		// fmt.Println("Player reached end of path:", a.fall.Outcome)
+		a.setPlayerCell(path[step].Cell)
+		if a.bounce != nil && a.fall.Outcome == BLOCKED {
+			return a.rebound(time)
+		}
+		return true
+	}
 
+	// Interpolate between the current and next cells
+	cell := path[step].Cell
+	next := path[step+1].Cell
 	for j := 0; j < 3; j++ {
-		pos[j] = float32(math.Round(float64(a.initial[j])))
-		cell[j] = int(pos[j])
-		next[j] = pos[j] + a.releaseAxis[j]
+		a.player[j] = float32(cell[j]) + float32(distance)*float32(next[j]-cell[j])
 	}
-	for ; step < steps; step++ {
-		// Check if player is out of bounds
-		if step > limit {
-			// fmt.Println("Player out of bounds")
-			a.setPlayerCell(cell)
-			return true
-		}
-
-		// Check if cell contains a goal
-		if a.goal[0] == pos[0] && a.goal[1] == pos[1] && a.goal[2] == pos[2] {
-			// fmt.Println("Player in Goal")
-			a.setPlayerCell(cell)
-			return true
-		}
+	// fmt.Println("Player", a.player)
+	return false
+}
 
-		if step > 0 {
-			// Check if cell contains a portal
-			for l, p := range a.portals {
-				if p[0] == pos[0] && p[1] == pos[1] && p[2] == pos[2] {
-					prev := [3]float32{
-						p[0],
-						p[1],
-						p[2],
-					}
-					var pair [3]float32
-					if l%2 == 0 {
-						pair = a.portals[l+1]
-					} else {
-						pair = a.portals[l-1]
-					}
-					// fmt.Println("Player moved through Portal at", prev, "to", pair)
-					// Move player to paired portal
-					for j := 0; j < 3; j++ {
-						a.player[j] += pair[j] - prev[j]
-						pos[j] = float32(pair[j])
-						cell[j] = int(pos[j])
-						next[j] = pos[j] + a.releaseAxis[j]
-					}
-					break
-				}
-			}
-		}
+type crumbleAnimation struct {
+	scales  []*float32
+	start   float64
+	started bool
+}
 
-		// Check if next cell is blocked
-		for _, b := range a.blocks {
-			if b[0] == next[0] && b[1] == next[1] && b[2] == next[2] {
-				// fmt.Println("Player stopped at Block")
-				// TODO handle bounce
-				a.setPlayerCell(cell)
-				return true
-			}
-		}
+// NewCrumbleAnimation shrinks crumbling blocks until they vanish.
+func NewCrumbleAnimation(scales ...*float32) Animation {
+	return &crumbleAnimation{
+		scales: scales,
+	}
+}
 
-		// Move to next cell
-		for j := 0; j < 3; j++ {
-			pos[j] = pos[j] + a.releaseAxis[j]
-			cell[j] = int(pos[j])
-			next[j] = pos[j] + a.releaseAxis[j]
-		}
+func (a *crumbleAnimation) Tick(now float64) bool {
+	if !a.started {
+		a.start = now
+		a.started = true
 	}
-	return false
+	elapsed := now - a.start
+	for _, s := range a.scales {
+		*s = float32(math.Max(0, 1-elapsed/CRUMBLE_TIME))
+	}
+	return elapsed >= CRUMBLE_TIME
 }
 
 type gameOverAnimation struct {
@@ -279,12 +345,41 @@ func NewGameOverAnimation(model *mgl32.Mat4) Animation {
 	}
 }
 
-func (a *gameOverAnimation) Tick() bool {
+func (a *gameOverAnimation) Tick(now float64) bool {
 	*a.model = a.model.Mul4(mgl32.Scale3D(0.75, 0.75, 0.75))
 	a.ticks++
 	return a.ticks >= 10
 }
 
+// rebound moves the ball back up from the block it landed on, and returns true once every rebound is over.
+func (a *releaseBallAnimation) rebound(time float64) bool {
+	// Moving through a portal takes no time, so only count the cells fallen through
+	cells := 0
+	for _, s := range a.fall.Path[1:] {
+		if !s.Portal {
+			cells++
+		}
+	}
+	// Time and speed of the first impact, from S = 0.5 * A * T * T and V = A * T
+	impact := math.Sqrt(2 * float64(cells) / ACCELERATION)
+	speed := math.Min(ACCELERATION*impact, math.Sqrt(2*ACCELERATION*MAX_REBOUND))
+	elapsed := time - impact
+	for i := 0; i < a.bounce.Rebounds; i++ {
+		speed *= a.bounce.Restitution
+		// Time for the ball to rise and fall back onto the block
+		duration := 2 * speed / ACCELERATION
+		if elapsed < duration {
+			height := (speed * elapsed) - (0.5 * ACCELERATION * elapsed * elapsed)
+			for j := 0; j < 3; j++ {
+				a.player[j] -= float32(height) * float32(a.fall.Landing[j])
+			}
+			return false
+		}
+		elapsed -= duration
+	}
+	return true
+}
+
 func (a *releaseBallAnimation) setPlayerCell(p [3]int) {
 	for j := 0; j < 3; j++ {
 		a.player[j] = float32(p[j])
@@ -4,6 +4,7 @@ import (
 	"aletheiaware.com/perspectivefungo"
 	"github.com/go-gl/mathgl/mgl32"
 	"github.com/stretchr/testify/assert"
+	"math"
 	"testing"
 )
 
@@ -12,10 +13,12 @@ func TestReleaseBallAnimation(t *testing.T) {
 	rotation := mgl32.Ident4()
 	t.Run("Goal", func(t *testing.T) {
 		player := [3]float32{0, 1, 0}
-		goal := [3]float32{0, -1, 0}
-		blocks := [][3]float32{}
-		portals := [][3]float32{}
-		a := perspectivefungo.NewReleaseBallAnimation(size, rotation, &player, goal, blocks, portals)
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:   size,
+			Player: []int{0, 1, 0},
+			Goal:   []int{0, -1, 0},
+		})
+		a := perspectivefungo.NewReleaseBallAnimation(&player, maze.Release(maze.Start(), perspectivefungo.Gravity(rotation)))
 		// After 1 second, player should be in goal
 		assert.True(t, a.Progress(1))
 		assert.Equal(t, float32(0), player[0])
@@ -24,12 +27,13 @@ func TestReleaseBallAnimation(t *testing.T) {
 	})
 	t.Run("Block", func(t *testing.T) {
 		player := [3]float32{0, 1, 0}
-		goal := [3]float32{1, 0, 0}
-		blocks := [][3]float32{
-			{0, -1, 0},
-		}
-		portals := [][3]float32{}
-		a := perspectivefungo.NewReleaseBallAnimation(size, rotation, &player, goal, blocks, portals)
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:   size,
+			Player: []int{0, 1, 0},
+			Goal:   []int{1, 0, 0},
+			Blocks: []int{0, -1, 0},
+		})
+		a := perspectivefungo.NewReleaseBallAnimation(&player, maze.Release(maze.Start(), perspectivefungo.Gravity(rotation)))
 		// After 1 second, player should be stopped at block
 		assert.True(t, a.Progress(1))
 		assert.Equal(t, float32(0), player[0])
@@ -38,17 +42,148 @@ func TestReleaseBallAnimation(t *testing.T) {
 	})
 	t.Run("Portal", func(t *testing.T) {
 		player := [3]float32{0, 1, 0}
-		goal := [3]float32{1, -1, 0}
-		blocks := [][3]float32{}
-		portals := [][3]float32{
-			{0, -1, 0},
-			{1, 1, 0},
-		}
-		a := perspectivefungo.NewReleaseBallAnimation(size, rotation, &player, goal, blocks, portals)
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:    size,
+			Player:  []int{0, 1, 0},
+			Goal:    []int{1, -1, 0},
+			Portals: []int{0, -1, 0, 1, 1, 0},
+		})
+		a := perspectivefungo.NewReleaseBallAnimation(&player, maze.Release(maze.Start(), perspectivefungo.Gravity(rotation)))
 		// After 1 second, player should be through portal and in goal
 		assert.True(t, a.Progress(1))
 		assert.Equal(t, float32(1), player[0])
 		assert.Equal(t, float32(-1), player[1])
 		assert.Equal(t, float32(0), player[2])
 	})
+	t.Run("Falling", func(t *testing.T) {
+		player := [3]float32{0, 1, 0}
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:   size,
+			Player: []int{0, 1, 0},
+			Goal:   []int{0, -1, 0},
+		})
+		a := perspectivefungo.NewReleaseBallAnimation(&player, maze.Release(maze.Start(), perspectivefungo.Gravity(rotation)))
+		// After 0.1 seconds, player should be part way between cells
+		assert.False(t, a.Progress(0.1))
+		assert.Equal(t, float32(0), player[0])
+		assert.InDelta(t, float32(1-0.4905), player[1], 0.0001)
+		assert.Equal(t, float32(0), player[2])
+	})
+}
+
+func TestBouncingBallAnimation(t *testing.T) {
+	size := uint(5)
+	rotation := mgl32.Ident4()
+	maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+		Size:   size,
+		Player: []int{0, 2, 0},
+		Goal:   []int{1, 0, 0},
+		Blocks: []int{0, -1, 0},
+	})
+	fall := maze.Release(maze.Start(), perspectivefungo.Gravity(rotation))
+	// Time the ball first lands on the block after falling 2 cells
+	impact := math.Sqrt(2 * 2 / perspectivefungo.ACCELERATION)
+	t.Run("Rebound", func(t *testing.T) {
+		player := [3]float32{0, 2, 0}
+		a := perspectivefungo.NewBouncingBallAnimation(&player, fall, &perspectivefungo.Bounce{
+			Restitution: 0.5,
+			Rebounds:    2,
+		})
+		assert.False(t, a.Progress(impact+0.02))
+		// Ball has risen back towards where it fell from, but no higher than the limit
+		assert.Equal(t, float32(0), player[0])
+		assert.Greater(t, player[1], float32(0))
+		assert.LessOrEqual(t, player[1], float32(perspectivefungo.MAX_REBOUND))
+		assert.Equal(t, float32(0), player[2])
+	})
+	t.Run("Settle", func(t *testing.T) {
+		for name, bounce := range map[string]*perspectivefungo.Bounce{
+			"None":    {Restitution: 0.5, Rebounds: 0},
+			"Single":  {Restitution: 0.5, Rebounds: 1},
+			"Many":    {Restitution: 0.3, Rebounds: 5},
+			"Elastic": {Restitution: 1, Rebounds: 3},
+		} {
+			t.Run(name, func(t *testing.T) {
+				player := [3]float32{0, 2, 0}
+				a := perspectivefungo.NewBouncingBallAnimation(&player, fall, bounce)
+				// Settles in the same cell as without bouncing
+				assert.True(t, a.Progress(10))
+				assert.Equal(t, [3]float32{0, 0, 0}, player)
+				assert.Equal(t, bounce.Rebounds == 0, a.Progress(impact+1e-6))
+			})
+		}
+	})
+	t.Run("Resting", func(t *testing.T) {
+		player := [3]float32{0, 0, 0}
+		resting := maze.Release(fall.State, perspectivefungo.Gravity(rotation))
+		a := perspectivefungo.NewBouncingBallAnimation(&player, resting, perspectivefungo.BallBounce)
+		// A ball already on the block has no speed to rebound with
+		assert.True(t, a.Progress(0))
+		assert.Equal(t, [3]float32{0, 0, 0}, player)
+	})
+	t.Run("Goal", func(t *testing.T) {
+		player := [3]float32{0, 1, 0}
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:   size,
+			Player: []int{0, 1, 0},
+			Goal:   []int{0, -1, 0},
+		})
+		a := perspectivefungo.NewBouncingBallAnimation(&player, maze.Release(maze.Start(), perspectivefungo.Gravity(rotation)), perspectivefungo.BallBounce)
+		// Only blocks cause a bounce
+		assert.True(t, a.Progress(1))
+		assert.Equal(t, [3]float32{0, -1, 0}, player)
+	})
+	t.Run("Switch", func(t *testing.T) {
+		player := [3]float32{0, 2, 0}
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:   size,
+			Player: []int{0, 2, 0},
+			Goal:   []int{1, -1, 0},
+			Blocks: []int{2, 0, 0},
+			Switches: []*perspectivefungo.GravitySwitch{
+				{Cell: [3]int{0, 0, 0}, Direction: [3]int{1, 0, 0}},
+			},
+		})
+		a := perspectivefungo.NewBouncingBallAnimation(&player, maze.Release(maze.Start(), perspectivefungo.Gravity(rotation)), perspectivefungo.BallBounce)
+		// Ball rebounds away from the block it landed on, not against the direction of release
+		assert.False(t, a.Progress(math.Sqrt(2*3/perspectivefungo.ACCELERATION)+0.02))
+		assert.Less(t, player[0], float32(1))
+		assert.Equal(t, float32(0), player[1])
+		assert.Equal(t, float32(0), player[2])
+		assert.True(t, a.Progress(10))
+		assert.Equal(t, [3]float32{1, 0, 0}, player)
+	})
+}
+
+func TestCrumbleAnimation(t *testing.T) {
+	scale := float32(1)
+	a := perspectivefungo.NewCrumbleAnimation(&scale)
+	assert.False(t, a.Tick(5))
+	assert.Equal(t, float32(1), scale)
+	// Shrinks as time passes
+	assert.False(t, a.Tick(5+perspectivefungo.CRUMBLE_TIME/2))
+	assert.InDelta(t, 0.5, scale, 1e-6)
+	// Then vanishes
+	assert.True(t, a.Tick(6))
+	assert.Equal(t, float32(0), scale)
+}
+
+func TestReleaseBallsAnimation(t *testing.T) {
+	maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+		Size:   5,
+		Player: []int{0, 2, 0, 0, 0, 0, 2, 2, 0},
+		Goal:   []int{2, -2, 0},
+		Blocks: []int{0, -2, 0},
+	})
+	fall := maze.Release(maze.Start(), [3]int{0, -1, 0})
+	balls := [][3]float32{{0, 2, 0}, {0, 0, 0}, {2, 2, 0}}
+	a := perspectivefungo.NewReleaseBallsAnimation(balls, fall, nil)
+	// Every ball falls at once
+	assert.False(t, a.Progress(0.1))
+	assert.Less(t, balls[0][1], float32(2))
+	assert.Less(t, balls[1][1], float32(0))
+	assert.Equal(t, balls[0][1], balls[2][1])
+	// Until the last ball comes to rest, on top of the ball below
+	assert.True(t, a.Progress(1))
+	assert.Equal(t, [][3]float32{{0, 0, 0}, {0, -1, 0}, {2, -2, 0}}, balls)
 }
@@ -20,6 +20,9 @@ var Player []byte
 //go:embed assets/Portal.off
 var Portal []byte
 
+//go:embed assets/Switch.off
+var Switch []byte
+
 //go:embed assets/Start.off
 var Start []byte
 
@@ -85,6 +88,10 @@ func LoadAssets(d Driver) error {
 		return err
 	}
 
+	if err := LoadOFFMesh(d, "switch", Switch, false); err != nil {
+		return err
+	}
+
 	if err := LoadOFFMesh(d, "start", Start, false); err != nil {
 		return err
 	}
@@ -0,0 +1,65 @@
+package perspectivefungo
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+)
+
+type Button string
+
+const (
+	BUTTON_NONE  Button = ""
+	BUTTON_RETRY Button = "retry"
+	BUTTON_SHARE Button = "share"
+)
+
+// ButtonMeshes holds the mesh data drawn for each button, from which their boxes are measured.
+var ButtonMeshes = map[Button][]byte{
+	BUTTON_RETRY: Retry,
+	BUTTON_SHARE: Share,
+}
+
+// button is a mesh drawn on screen which can be tapped.
+type button struct {
+	id    Button
+	color *mgl32.Vec4
+	model mgl32.Mat4
+}
+
+func (b *button) draw(d Driver) error {
+	d.SetColor(b.color)
+	d.SetModel(&b.model)
+	return d.DrawMesh(string(b.id))
+}
+
+// contains returns true if the screen position, measured in pixels from the top left, lies within the projection of the box.
+func (b *button) contains(box *Box, transform mgl32.Mat4, width, height, x, y float32) bool {
+	// Corners may project off screen, so the bounds start unbounded rather than at the screen edges
+	inf := float32(math.Inf(1))
+	var (
+		min = mgl32.Vec2{inf, inf}
+		max = mgl32.Vec2{-inf, -inf}
+	)
+	mvp := transform.Mul4(b.model)
+	for _, c := range box.Corners() {
+		clip := mvp.Mul4x1(c.Vec4(1))
+		if clip[3] <= 0 {
+			// Behind the camera
+			return false
+		}
+		// Normalized device coordinates to pixels, flipping y so it increases downwards
+		s := mgl32.Vec2{
+			(clip[0]/clip[3] + 1) / 2 * width,
+			(1 - clip[1]/clip[3]) / 2 * height,
+		}
+		for i := 0; i < 2; i++ {
+			if s[i] < min[i] {
+				min[i] = s[i]
+			}
+			if s[i] > max[i] {
+				max[i] = s[i]
+			}
+		}
+	}
+	return x >= min[0] && x <= max[0] && y >= min[1] && y <= max[1]
+}
@@ -0,0 +1,35 @@
+package perspectivefungo_test
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestButtonContains(t *testing.T) {
+	// Without a transform the box is in normalized device coordinates, so on a 100x100 screen x spans -1..1 as 0..100 pixels
+	contains := func(box *perspectivefungo.Box, x, y float32) bool {
+		return perspectivefungo.ButtonContains(box, mgl32.Ident4(), 100, 100, x, y)
+	}
+	t.Run("OnScreen", func(t *testing.T) {
+		box := &perspectivefungo.Box{Min: mgl32.Vec3{-0.5, -0.5, 0}, Max: mgl32.Vec3{0.5, 0.5, 0}}
+		assert.True(t, contains(box, 50, 50))
+		assert.False(t, contains(box, 10, 50))
+	})
+	t.Run("PartlyOffScreen", func(t *testing.T) {
+		// Spans -25..25 pixels
+		box := &perspectivefungo.Box{Min: mgl32.Vec3{-1.5, -0.5, 0}, Max: mgl32.Vec3{-0.5, 0.5, 0}}
+		assert.True(t, contains(box, 10, 50))
+		assert.True(t, contains(box, -10, 50))
+		assert.False(t, contains(box, 30, 50))
+		assert.False(t, contains(box, -30, 50))
+	})
+	t.Run("OffScreen", func(t *testing.T) {
+		// Spans -100..-50 pixels, so doesn't reach the edge of the screen
+		box := &perspectivefungo.Box{Min: mgl32.Vec3{-3, -0.5, 0}, Max: mgl32.Vec3{-2, 0.5, 0}}
+		assert.False(t, contains(box, 0, 50))
+		assert.False(t, contains(box, -10, 50))
+		assert.True(t, contains(box, -75, 50))
+	})
+}
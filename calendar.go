@@ -0,0 +1,101 @@
+package perspectivefungo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const DATE_FORMAT = "2006-01-02"
+
+type Difficulty struct {
+	Size      uint
+	Blocks    uint
+	Portals   uint
+	Rotations uint
+}
+
+// Difficulties increase throughout the week, starting off Super Easy on Sunday, getting Quite Tricky on Wednesday, and finally reaching Brutally Hard on Saturday.
+var Difficulties = map[time.Weekday]*Difficulty{
+	time.Sunday:    {Size: 5, Blocks: 2, Portals: 0, Rotations: 1},
+	time.Monday:    {Size: 5, Blocks: 4, Portals: 2, Rotations: 2},
+	time.Tuesday:   {Size: 7, Blocks: 6, Portals: 2, Rotations: 3},
+	time.Wednesday: {Size: 7, Blocks: 8, Portals: 2, Rotations: 4},
+	time.Thursday:  {Size: 9, Blocks: 8, Portals: 4, Rotations: 5},
+	time.Friday:    {Size: 9, Blocks: 10, Portals: 4, Rotations: 6},
+	time.Saturday:  {Size: 11, Blocks: 12, Portals: 4, Rotations: 7},
+}
+
+// DailyFilename returns the name of the file containing the puzzle for the given date.
+func DailyFilename(date time.Time) string {
+	return date.UTC().Format(DATE_FORMAT) + ".json"
+}
+
+// ParseDateRange parses an inclusive range of dates, such as 2026-11-01..2026-11-30.
+func ParseDateRange(s string) (time.Time, time.Time, error) {
+	parts := strings.Split(s, "..")
+	if len(parts) != 2 {
+		return time.Time{}, time.Time{}, fmt.Errorf("Invalid date range: %s", s)
+	}
+	start, err := time.Parse(DATE_FORMAT, parts[0])
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := time.Parse(DATE_FORMAT, parts[1])
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("Invalid date range: %s ends before it starts", s)
+	}
+	return start, end, nil
+}
+
+// Dates returns every day from start to end inclusive.
+func Dates(start, end time.Time) []time.Time {
+	var dates []time.Time
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		dates = append(dates, d)
+	}
+	return dates
+}
+
+// Today returns the start of the current day in UTC.
+func Today() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
+
+// GenerateDaily generates a puzzle with the difficulty of the date's weekday.
+// Each date uses a different sequence of seeds so the same seed produces a different puzzle for every day.
+func GenerateDaily(ctx context.Context, date time.Time, seed int64, workers int, progress func(*GeneratorProgress)) (*Puzzle, error) {
+	d, ok := Difficulties[date.Weekday()]
+	if !ok {
+		return nil, fmt.Errorf("Missing difficulty for %s", date.Weekday())
+	}
+	return GenerateBest(ctx, &GeneratorOptions{
+		Size:      d.Size,
+		Blocks:    d.Blocks,
+		Portals:   d.Portals,
+		Rotations: d.Rotations,
+		Penalties: math.MaxUint32,
+		Seed:      rand.New(rand.NewSource(seed + date.Unix())).Int63(),
+		Workers:   workers,
+		Interval:  time.Minute,
+	}, progress)
+}
+
+// CoveredDays returns the number of consecutive days, starting with the given date, which have a puzzle in the directory.
+func CoveredDays(directory string, from time.Time) int {
+	days := 0
+	for d := from; ; d = d.AddDate(0, 0, 1) {
+		if _, err := os.Stat(filepath.Join(directory, DailyFilename(d))); err != nil {
+			return days
+		}
+		days++
+	}
+}
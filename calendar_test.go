@@ -0,0 +1,105 @@
+package perspectivefungo_test
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseDateRange(t *testing.T) {
+	for name, tt := range map[string]struct {
+		input string
+		start string
+		end   string
+		err   bool
+	}{
+		"Single": {
+			input: "2026-11-01..2026-11-01",
+			start: "2026-11-01",
+			end:   "2026-11-01",
+		},
+		"Month": {
+			input: "2026-11-01..2026-11-30",
+			start: "2026-11-01",
+			end:   "2026-11-30",
+		},
+		"Reversed": {
+			input: "2026-11-30..2026-11-01",
+			err:   true,
+		},
+		"Missing": {
+			input: "2026-11-01",
+			err:   true,
+		},
+		"Malformed": {
+			input: "2026-11-01..tomorrow",
+			err:   true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			start, end, err := perspectivefungo.ParseDateRange(tt.input)
+			if tt.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.start, start.Format(perspectivefungo.DATE_FORMAT))
+			assert.Equal(t, tt.end, end.Format(perspectivefungo.DATE_FORMAT))
+		})
+	}
+}
+
+func TestDates(t *testing.T) {
+	start, end, err := perspectivefungo.ParseDateRange("2026-10-30..2026-11-02")
+	assert.Nil(t, err)
+	var names []string
+	for _, d := range perspectivefungo.Dates(start, end) {
+		names = append(names, perspectivefungo.DailyFilename(d))
+	}
+	assert.Equal(t, []string{"2026-10-30.json", "2026-10-31.json", "2026-11-01.json", "2026-11-02.json"}, names)
+}
+
+func TestCoveredDays(t *testing.T) {
+	dir := t.TempDir()
+	start, end, err := perspectivefungo.ParseDateRange("2026-11-01..2026-11-05")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, perspectivefungo.CoveredDays(dir, start))
+	for _, d := range perspectivefungo.Dates(start, end) {
+		if d.Day() == 4 {
+			continue
+		}
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, perspectivefungo.DailyFilename(d)), []byte("{}"), 0644))
+	}
+	assert.Equal(t, 3, perspectivefungo.CoveredDays(dir, start))
+	assert.Equal(t, 1, perspectivefungo.CoveredDays(dir, start.AddDate(0, 0, 4)))
+}
+
+func TestDifficulties(t *testing.T) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		t.Run(day.String(), func(t *testing.T) {
+			d, ok := perspectivefungo.Difficulties[day]
+			assert.True(t, ok)
+			_, err := perspectivefungo.Generate(1, d.Size, d.Blocks, d.Portals)
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestGenerateDaily(t *testing.T) {
+	date, err := time.Parse(perspectivefungo.DATE_FORMAT, "2026-11-01")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Sunday, date.Weekday())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	a, err := perspectivefungo.GenerateDaily(ctx, date, 1234, 1, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, a.Validate())
+	assert.Equal(t, uint(5), a.Size)
+	b, err := perspectivefungo.GenerateDaily(ctx, date.AddDate(0, 0, 7), 1234, 1, nil)
+	assert.Nil(t, err)
+	assert.NotEqual(t, a.Seed, b.Seed)
+}
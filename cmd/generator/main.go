@@ -2,14 +2,22 @@ package main
 
 import (
 	"aletheiaware.com/perspectivefungo"
+	"context"
 	"encoding/json"
 	"flag"
 	"log"
 	"math"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"time"
 )
 
-const MAX_TRIES = 1000000000
+const (
+	MAX_TRIES        = 1000000000
+	CALENDAR_TIMEOUT = 30 * time.Minute // Default time spent generating each day of a calendar
+)
 
 var (
 	size      = flag.Uint("size", 5, "Puzzle size")
@@ -17,26 +25,87 @@ var (
 	penalties = flag.Uint("penalties", math.MaxUint, "Maximum puzzle penalties")
 	blocks    = flag.Uint("blocks", 4, "Number of blocks")
 	portals   = flag.Uint("portals", 2, "Number of portals")
+	seed      = flag.Int64("seed", 0, "Random seed (default current time)")
+	tries     = flag.Uint64("tries", MAX_TRIES, "Maximum number of tries")
+	workers   = flag.Int("workers", runtime.NumCPU(), "Number of parallel workers")
+	timeout   = flag.Duration("timeout", 0, "Stop and write the best puzzle found after this long (default no timeout, or 30m for each day of a calendar)")
+	interval  = flag.Duration("interval", 10*time.Second, "How often progress is logged")
+
+	optimise    = flag.Bool("optimise", false, "Optimise the generated puzzle")
+	input       = flag.String("input", "", "Optimise the puzzle in this file instead of generating one")
+	objective   = flag.String("objective", "rotations", "Optimisation objective; rotations (maximise), penalties (minimise), or target (exact rotations)")
+	iterations  = flag.Uint64("iterations", 100000, "Number of optimisation iterations")
+	temperature = flag.Float64("temperature", 1, "Initial optimisation temperature")
+	maxBlocks   = flag.Uint("maxblocks", 0, "Maximum number of blocks when optimising (default no limit)")
+
+	calendar = flag.String("calendar", "", "Generate a puzzle for each day in the range, such as 2026-11-01..2026-11-30, into the output directory")
 )
 
 func main() {
 	flag.Parse()
 
-	var puzzle *perspectivefungo.Puzzle
+	if !isSet("seed") {
+		// Zero is a valid seed, so only an unset flag uses the current time
+		*seed = time.Now().UnixNano()
+	}
+	log.Println("Seed:", *seed)
+
+	// Stop early on interrupt, or when timeout is reached
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
 
-	for i, best := 0, *rotations/2; i < MAX_TRIES && best < *rotations; i++ {
-		p, err := perspectivefungo.Generate(*size, *blocks, *portals)
+	if *calendar != "" {
+		directory := "puzzles"
+		if args := flag.Args(); len(args) > 0 {
+			directory = args[0]
+		}
+		if err := generateCalendar(ctx, directory); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	var (
+		puzzle *perspectivefungo.Puzzle
+		err    error
+	)
+	if *input != "" {
+		puzzle, err = read(*input)
+	} else {
+		puzzle, err = generate(ctx)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *optimise || *input != "" {
+		o, err := perspectivefungo.ParseObjective(*objective)
 		if err != nil {
 			log.Fatal(err)
 		}
-		rs, ps := perspectivefungo.Score(p)
-		if best < rs && ps <= *penalties {
-			log.Println("Iteration:", i)
-			log.Println("Size:", *size)
-			log.Println("Rotations:", rs, "/", *rotations)
-			log.Println("Penalties:", ps)
-			puzzle = p
-			best = rs
+		log.Println("Optimising:", o)
+		puzzle, err = perspectivefungo.Optimise(ctx, puzzle, &perspectivefungo.OptimiserOptions{
+			Objective:   o,
+			Target:      *rotations,
+			MaxBlocks:   *maxBlocks,
+			Iterations:  *iterations,
+			Temperature: *temperature,
+			Seed:        *seed,
+			Interval:    *interval,
+		}, func(p *perspectivefungo.OptimiserProgress) {
+			log.Printf("Iteration: %d / %d", p.Iteration, *iterations)
+			log.Println("Cost:", p.Cost)
+			log.Println("Rotations:", p.Rotations, "/", *rotations)
+			log.Println("Penalties:", p.Penalties)
+			log.Println("Blocks:", len(p.Best.Blocks)/3)
+		})
+		if err != nil {
+			log.Fatal(err)
 		}
 	}
 
@@ -55,3 +124,91 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// isSet returns true if the named flag was given on the command line.
+func isSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func generate(ctx context.Context) (*perspectivefungo.Puzzle, error) {
+	return perspectivefungo.GenerateBest(ctx, &perspectivefungo.GeneratorOptions{
+		Size:      *size,
+		Blocks:    *blocks,
+		Portals:   *portals,
+		Rotations: *rotations,
+		Penalties: *penalties,
+		Seed:      *seed,
+		Tries:     *tries,
+		Workers:   *workers,
+		Interval:  *interval,
+	}, func(p *perspectivefungo.GeneratorProgress) {
+		log.Printf("Tries: %d (%.0f/s)", p.Tries, p.Rate())
+		if p.Best != nil {
+			log.Println("Seed:", *p.Best.Seed)
+			log.Println("Size:", *size)
+			log.Println("Rotations:", p.Rotations, "/", *rotations)
+			log.Println("Penalties:", p.Penalties)
+		}
+	})
+}
+
+func read(name string) (*perspectivefungo.Puzzle, error) {
+	log.Println("Reading:", name)
+	return perspectivefungo.ReadPuzzle(name)
+}
+
+// generateCalendar writes a puzzle for each day in the calendar which doesn't already have one, the timeout (or CALENDAR_TIMEOUT) applies to each day.
+func generateCalendar(ctx context.Context, directory string) error {
+	start, end, err := perspectivefungo.ParseDateRange(*calendar)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(directory, os.ModePerm); err != nil {
+		return err
+	}
+	for _, date := range perspectivefungo.Dates(start, end) {
+		name := filepath.Join(directory, perspectivefungo.DailyFilename(date))
+		if _, err := os.Stat(name); err == nil {
+			log.Println("Exists:", name)
+			continue
+		}
+		if err := generateDay(ctx, name, date); err != nil {
+			return err
+		}
+	}
+	log.Println("Puzzles Scheduled:", perspectivefungo.CoveredDays(directory, perspectivefungo.Today()), "days")
+	return nil
+}
+
+func generateDay(ctx context.Context, name string, date time.Time) error {
+	d := perspectivefungo.Difficulties[date.Weekday()]
+	log.Println("Generating:", name, date.Weekday(), "Size:", d.Size, "Rotations:", d.Rotations)
+	t := *timeout
+	if t <= 0 {
+		// Harder days could otherwise run forever
+		t = CALENDAR_TIMEOUT
+	}
+	c, cancel := context.WithTimeout(ctx, t)
+	defer cancel()
+	p, err := perspectivefungo.GenerateDaily(c, date, *seed, *workers, func(p *perspectivefungo.GeneratorProgress) {
+		log.Printf("Tries: %d (%.0f/s)", p.Tries, p.Rate())
+		if p.Best != nil {
+			log.Println("Rotations:", p.Rotations, "/", d.Rotations)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		// Interrupted, don't write a puzzle that may not be the best
+		return ctx.Err()
+	}
+	log.Println("Writing:", name, "Seed:", *p.Seed)
+	return perspectivefungo.WritePuzzle(name, p)
+}
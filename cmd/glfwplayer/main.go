@@ -6,7 +6,6 @@ import (
 	"flag"
 	"io"
 	"log"
-	"math"
 	"net/http"
 	"os"
 	"runtime"
@@ -21,6 +20,8 @@ var (
 	width  = 800
 	height = 600
 	daily  = flag.Bool("daily", false, "Daily Puzzle")
+	replay = flag.String("replay", "", "Replay Solution")
+	keys   = flag.String("keys", "", "Key Bindings JSON File")
 )
 
 func init() {
@@ -74,27 +75,68 @@ func main() {
 		if err := json.NewDecoder(reader).Decode(&puzzle); err != nil {
 			log.Fatal(err)
 		}
+		if err := puzzle.Validate(); err != nil {
+			log.Fatal(err)
+		}
 	} else {
 		puzzle.Size = 5
 		puzzle.Player = []int{0, 1, 0}
 		puzzle.Goal = []int{0, -1, 0}
 	}
-	game := perspectivefungo.NewGame(&puzzle)
-
-	/*
-		game := &Recorder{
-			Output: args[0],
-			Mesh:   "goal",
-			Color:  perspectivefungo.GoalColor,
-			Count:  1, //64 - 1,
+	var (
+		game  perspectivefungo.Game
+		input *perspectivefungo.InputController
+	)
+	if *replay != "" {
+		r, err := loadReplay(&puzzle, *replay)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Space: Pause/Resume | Right: Step | Up/Down: Speed | R: Restart")
+		window.SetKeyCallback(func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+			if action != glfw.Press {
+				return
+			}
+			switch key {
+			case glfw.KeySpace:
+				r.SetPaused(!r.Paused())
+			case glfw.KeyRight:
+				r.Step()
+			case glfw.KeyUp:
+				r.SetSpeed(r.Speed() * 2)
+				log.Println("Speed:", r.Speed())
+			case glfw.KeyDown:
+				r.SetSpeed(r.Speed() / 2)
+				log.Println("Speed:", r.Speed())
+			case glfw.KeyR:
+				r.Reset()
+			}
+		})
+		game = r
+		input = perspectivefungo.NewInputController(game, nil)
+	} else {
+		bindings, err := loadKeyBindings(*keys)
+		if err != nil {
+			log.Fatal(err)
 		}
-	*/
+		game = perspectivefungo.NewGame(&puzzle)
+		input = perspectivefungo.NewInputController(game, bindings)
+		window.SetKeyCallback(func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+			if action != glfw.Press {
+				return
+			}
+			input.Key(keyName(key, scancode))
+		})
+	}
+	input.OnShare = func(*perspectivefungo.Solution) {
+		log.Println("Not Supported")
+	}
 
 	if err := d.Init(game); err != nil {
 		log.Fatal(err)
 	}
 
-	game.Resize(float32(width), float32(height))
+	input.Resize(float64(width), float64(height))
 
 	window.SetCloseCallback(func(w *glfw.Window) {
 		// Do Nothing
@@ -104,45 +146,24 @@ func main() {
 		// Do Nothing
 	})
 
-	scale := math.Min(float64(width), float64(height))
-	threshold := scale / 100
-
 	window.SetFramebufferSizeCallback(func(w *glfw.Window, width, height int) {
 		gl.Viewport(0, 0, int32(width), int32(height))
-		if game != nil {
-			game.Resize(float32(width), float32(height))
-		}
-		scale = math.Min(float64(width), float64(height))
-		threshold = scale / 100
+		input.Resize(float64(width), float64(height))
 	})
 
 	window.SetRefreshCallback(func(w *glfw.Window) {
 		// Do Nothing
 	})
 
-	var (
-		dragging     bool
-		rotated      bool
-		lastX, lastY float64
-	)
 	window.SetCursorPosCallback(func(w *glfw.Window, x, y float64) {
-		if game == nil || game.Animating() {
-			return
-		}
-		if dragging {
-			deltaX := x - lastX
-			deltaY := y - lastY
-			if math.Abs(deltaX) > threshold || math.Abs(deltaY) > threshold {
-				rotated = true
-				radX := float32((deltaY / scale) * 2.0 * math.Pi)
-				radY := float32((deltaX / scale) * 2.0 * math.Pi)
-				game.Rotate(radX, radY)
-			} else {
-				// Don't update lastX or lastY
-				return
-			}
+		input.Move(pixels(w, x, y))
+	})
+
+	window.SetFocusCallback(func(w *glfw.Window, focused bool) {
+		if !focused {
+			// The button may be released elsewhere
+			input.Cancel()
 		}
-		lastX, lastY = x, y
 	})
 
 	window.SetMouseButtonCallback(func(w *glfw.Window, btn glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
@@ -150,36 +171,14 @@ func main() {
 			// Do Nothing
 			return
 		}
-		if game == nil || game.Animating() {
-			return
-		}
+		x, y := w.GetCursorPos()
+		x, y = pixels(w, x, y)
 		switch action {
 		case glfw.Press:
-			dragging = true
+			input.Down(x, y)
 		case glfw.Release:
-			dragging = false
-			if rotated {
-				game.RotateToAxis()
-			} else if !game.HasGameStarted() {
-				game.Start()
-			} else if game.HasGameEnded() {
-				s := game.Solution()
-				if s == nil {
-					game.Reset()
-					game.Start()
-				} else {
-					if lastX < float64(width)/2 {
-						game.Reset()
-						game.Start()
-					} else {
-						log.Println("Not Supported")
-					}
-				}
-			} else {
-				game.ReleaseBall()
-			}
+			input.Up(x, y)
 		}
-		rotated = false
 	})
 
 	for !window.ShouldClose() {
@@ -191,3 +190,63 @@ func main() {
 		glfw.PollEvents()
 	}
 }
+
+func loadReplay(puzzle *perspectivefungo.Puzzle, name string) (perspectivefungo.Replay, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var solution perspectivefungo.Solution
+	if err := json.Unmarshal(data, &solution); err != nil {
+		return nil, err
+	}
+	if err := solution.Verify(puzzle); err != nil {
+		// Still replay invalid solutions so they can be reviewed
+		log.Println(err)
+	}
+	return perspectivefungo.NewReplay(puzzle, &solution)
+}
+
+func loadKeyBindings(name string) (perspectivefungo.KeyBindings, error) {
+	if name == "" {
+		return perspectivefungo.DefaultKeyBindings(), nil
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return perspectivefungo.LoadKeyBindings(file)
+}
+
+// keyName returns the name of the key as used by KeyBindings.
+func keyName(key glfw.Key, scancode int) string {
+	switch key {
+	case glfw.KeySpace:
+		return "space"
+	case glfw.KeyEnter:
+		return "enter"
+	case glfw.KeyEscape:
+		return "escape"
+	case glfw.KeyUp:
+		return "up"
+	case glfw.KeyDown:
+		return "down"
+	case glfw.KeyLeft:
+		return "left"
+	case glfw.KeyRight:
+		return "right"
+	}
+	// Printable keys are named by the character they produce in the current layout
+	return glfw.GetKeyName(key, scancode)
+}
+
+// pixels converts a cursor position from screen coordinates to framebuffer pixels, which differ on high density displays.
+func pixels(w *glfw.Window, x, y float64) (float64, float64) {
+	fw, fh := w.GetFramebufferSize()
+	ww, wh := w.GetSize()
+	if ww == 0 || wh == 0 {
+		return x, y
+	}
+	return x * float64(fw) / float64(ww), y * float64(fh) / float64(wh)
+}
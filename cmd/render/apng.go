@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+type chunk struct {
+	kind string
+	data []byte
+}
+
+// EncodeAPNG writes the frames as an animated PNG which loops forever, showing each frame for numerator/denominator seconds.
+// Every frame must encode with the same color type, bit depth, and palette as the first, as they share its header.
+func EncodeAPNG(w io.Writer, frames []image.Image, numerator, denominator uint16) error {
+	if len(frames) == 0 {
+		return errors.New("No frames to encode")
+	}
+	if _, err := w.Write(pngSignature); err != nil {
+		return err
+	}
+	bounds := frames[0].Bounds()
+	var (
+		first    map[string][]byte // Header and palette of the first frame, which the others must match
+		sequence uint32
+	)
+	for i, f := range frames {
+		if f.Bounds() != bounds {
+			return errors.New("Frames must all be the same size")
+		}
+		var buffer bytes.Buffer
+		if err := png.Encode(&buffer, f); err != nil {
+			return err
+		}
+		chunks, err := readChunks(buffer.Bytes())
+		if err != nil {
+			return err
+		}
+		header := make(map[string][]byte)
+		for _, c := range chunks {
+			switch c.kind {
+			case "IHDR", "PLTE", "tRNS":
+				header[c.kind] = c.data
+			}
+		}
+		if i == 0 {
+			first = header
+		} else if !sameHeader(first, header) {
+			return fmt.Errorf("Frame %d encodes differently to the first frame", i)
+		}
+		for _, c := range chunks {
+			switch c.kind {
+			case "IHDR":
+				if i > 0 {
+					continue
+				}
+				if err := writeChunk(w, c.kind, c.data); err != nil {
+					return err
+				}
+				// Animation control must come before the first image data
+				actl := make([]byte, 8)
+				binary.BigEndian.PutUint32(actl[0:], uint32(len(frames)))
+				binary.BigEndian.PutUint32(actl[4:], 0) // Loop forever
+				if err := writeChunk(w, "acTL", actl); err != nil {
+					return err
+				}
+				if err := writeChunk(w, "fcTL", frameControl(sequence, bounds, numerator, denominator)); err != nil {
+					return err
+				}
+				sequence++
+			case "IDAT":
+				if i == 0 {
+					if err := writeChunk(w, c.kind, c.data); err != nil {
+						return err
+					}
+					continue
+				}
+				if c == chunks[firstIDAT(chunks)] {
+					if err := writeChunk(w, "fcTL", frameControl(sequence, bounds, numerator, denominator)); err != nil {
+						return err
+					}
+					sequence++
+				}
+				fdat := make([]byte, 4+len(c.data))
+				binary.BigEndian.PutUint32(fdat, sequence)
+				copy(fdat[4:], c.data)
+				if err := writeChunk(w, "fdAT", fdat); err != nil {
+					return err
+				}
+				sequence++
+			case "PLTE", "tRNS":
+				// Palettes are shared by all frames
+				if i > 0 {
+					continue
+				}
+				if err := writeChunk(w, c.kind, c.data); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return writeChunk(w, "IEND", nil)
+}
+
+func sameHeader(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || !bytes.Equal(v, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// frameControl returns the data of an fcTL chunk for a full size frame, with zero offsets, and no dispose or blend operations.
+func frameControl(sequence uint32, bounds image.Rectangle, numerator, denominator uint16) []byte {
+	fctl := make([]byte, 26)
+	binary.BigEndian.PutUint32(fctl[0:], sequence)
+	binary.BigEndian.PutUint32(fctl[4:], uint32(bounds.Dx()))
+	binary.BigEndian.PutUint32(fctl[8:], uint32(bounds.Dy()))
+	binary.BigEndian.PutUint16(fctl[20:], numerator)
+	binary.BigEndian.PutUint16(fctl[22:], denominator)
+	return fctl
+}
+
+func firstIDAT(chunks []*chunk) int {
+	for i, c := range chunks {
+		if c.kind == "IDAT" {
+			return i
+		}
+	}
+	return -1
+}
+
+func readChunks(data []byte) ([]*chunk, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("Invalid PNG signature")
+	}
+	data = data[len(pngSignature):]
+	var chunks []*chunk
+	for len(data) >= 12 {
+		length := binary.BigEndian.Uint32(data)
+		if uint32(len(data)) < 12+length {
+			return nil, errors.New("Truncated PNG chunk")
+		}
+		chunks = append(chunks, &chunk{
+			kind: string(data[4:8]),
+			data: data[8 : 8+length],
+		})
+		data = data[12+length:]
+	}
+	return chunks, nil
+}
+
+func writeChunk(w io.Writer, kind string, data []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], kind)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+	for _, b := range [][]byte{header, data, footer} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
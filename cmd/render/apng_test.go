@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestEncodeAPNG(t *testing.T) {
+	var frames []image.Image
+	for _, c := range []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}} {
+		img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+		frames = append(frames, img)
+	}
+	var buffer bytes.Buffer
+	assert.Nil(t, EncodeAPNG(&buffer, frames, 1, 25))
+
+	// Decoders without animation support show the first frame
+	first, err := png.Decode(bytes.NewReader(buffer.Bytes()))
+	assert.Nil(t, err)
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, color.RGBAModel.Convert(first.At(4, 4)))
+
+	chunks, err := readChunks(buffer.Bytes())
+	assert.Nil(t, err)
+	var kinds []string
+	var sequences []uint32
+	for _, c := range chunks {
+		kinds = append(kinds, c.kind)
+		switch c.kind {
+		case "acTL":
+			assert.Equal(t, uint32(len(frames)), binary.BigEndian.Uint32(c.data))
+		case "fcTL", "fdAT":
+			sequences = append(sequences, binary.BigEndian.Uint32(c.data))
+		}
+	}
+	assert.Equal(t, []string{"IHDR", "acTL", "fcTL", "IDAT", "fcTL", "fdAT", "fcTL", "fdAT", "IEND"}, kinds)
+	assert.Equal(t, []uint32{0, 1, 2, 3, 4}, sequences)
+
+	assert.NotNil(t, EncodeAPNG(&buffer, nil, 1, 25))
+
+	// A translucent frame would encode with an alpha channel the opaque first frame lacks
+	translucent := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	assert.NotNil(t, EncodeAPNG(&buffer, append(frames, translucent), 1, 25))
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"aletheiaware.com/perspectivefungo/headless"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DEFAULT_DURATION = 4 * time.Second
+
+var (
+	width    = flag.Int("width", 400, "Width in pixels")
+	height   = flag.Int("height", 400, "Height in pixels")
+	fps      = flag.Int("fps", 25, "Frames per second")
+	duration = flag.Duration("duration", 0, "Length of animation (default length of solution plus 2s, otherwise 4s)")
+	format   = flag.String("format", "", "Output format; png, gif, or apng (default from output extension)")
+	camera   = flag.String("camera", "fixed", "Camera path; fixed, orbit, or spin")
+	samples  = flag.Int("samples", 2, "Samples per pixel along each axis for anti-aliasing")
+	mesh     = flag.String("mesh", "", "Render a single mesh instead of a puzzle")
+	colour   = flag.String("color", "", "Color of mesh as hex, such as 00CC00E6 (default goal color)")
+	solution = flag.String("solution", "", "Replay a recorded solution")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: render [flags] [puzzle.json] output")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	output := args[len(args)-1]
+
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(output)) {
+		case ".gif":
+			*format = "gif"
+		case ".apng":
+			*format = "apng"
+		default:
+			*format = "png"
+		}
+	}
+
+	path, ok := CameraPaths[*camera]
+	if !ok {
+		log.Fatal("Unrecognized camera path: ", *camera)
+	}
+
+	if *fps <= 0 || *width <= 0 || *height <= 0 || *samples <= 0 {
+		log.Fatal("Frame rate, size, and samples must be positive")
+	}
+
+	var game perspectivefungo.Game
+	length := *duration
+	if *mesh != "" {
+		c := perspectivefungo.GoalColor
+		if *colour != "" {
+			var err error
+			c, err = parseColor(*colour)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		game = newMeshScene(*mesh, c)
+	} else {
+		if len(args) < 2 {
+			log.Fatal("Missing puzzle")
+		}
+		puzzle, err := perspectivefungo.ReadPuzzle(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := puzzle.Validate(); err != nil {
+			log.Fatal(err)
+		}
+		if *solution != "" {
+			s, err := readSolution(*solution)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := s.Verify(puzzle); err != nil {
+				// Still render invalid solutions so they can be reviewed
+				log.Println(err)
+			}
+			r, err := perspectivefungo.NewReplay(puzzle, s)
+			if err != nil {
+				log.Fatal(err)
+			}
+			game = r
+			if length == 0 {
+				length = s.End.Sub(s.Start) + 2*time.Second
+			}
+		} else {
+			game = perspectivefungo.NewGame(puzzle)
+		}
+	}
+	if length == 0 {
+		length = DEFAULT_DURATION
+	}
+
+	frames, err := renderFrames(game, path, *width, *height, *samples, *fps, length, *format == "png")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Writing:", output)
+	file, err := os.Create(output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	switch *format {
+	case "png":
+		err = png.Encode(file, frames[0])
+	case "gif":
+		err = encodeGIF(file, frames, *fps)
+	case "apng":
+		var images []image.Image
+		for _, f := range frames {
+			images = append(images, f)
+		}
+		err = EncodeAPNG(file, images, 1, uint16(*fps))
+	default:
+		err = fmt.Errorf("Unrecognized format: %s", *format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// renderFrames plays the game from the start for the length of time, returning a frame for each step of the frame rate, or when still only the frame at the end.
+func renderFrames(game perspectivefungo.Game, path CameraPath, width, height, samples, fps int, length time.Duration, still bool) ([]*image.RGBA, error) {
+	count := int(math.Ceil(length.Seconds() * float64(fps)))
+	if count < 1 {
+		count = 1
+	}
+
+	g := &cameraGame{
+		Game: game,
+	}
+	d := headless.NewDriver(width*samples, height*samples)
+	if err := d.Init(g); err != nil {
+		return nil, err
+	}
+	g.Start()
+
+	if still {
+		// Replays only advance as time passes between frames, so play through to the end before drawing it
+		log.Println("Rendering: 1 frame after", count, "steps")
+		g.driver.transform = path(0)
+		for i := 0; i < count; i++ {
+			if err := d.Loop(float64(i) / float64(fps)); err != nil {
+				return nil, err
+			}
+		}
+		if err := d.Loop(length.Seconds()); err != nil {
+			return nil, err
+		}
+		return []*image.RGBA{downsample(d.Image(), samples)}, nil
+	}
+
+	log.Println("Rendering:", count, "frames")
+	var frames []*image.RGBA
+	for i := 0; i < count; i++ {
+		g.driver.transform = path(float64(i) / float64(count))
+		if err := d.Loop(float64(i) / float64(fps)); err != nil {
+			return nil, err
+		}
+		frames = append(frames, downsample(d.Image(), samples))
+	}
+	return frames, nil
+}
+
+func readSolution(name string) (*perspectivefungo.Solution, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	s := &perspectivefungo.Solution{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func encodeGIF(file *os.File, frames []*image.RGBA, fps int) error {
+	palette := Quantise(frames, MAX_PALETTE_SIZE)
+	log.Println("Colors:", len(palette))
+	cache := make(map[color.RGBA]uint8)
+	// GIF delays are in hundredths of a second
+	delay := int(math.Round(100 / float64(fps)))
+	if delay < 1 {
+		delay = 1
+	}
+	animation := &gif.GIF{}
+	for _, f := range frames {
+		animation.Image = append(animation.Image, Paletted(f, palette, cache))
+		animation.Delay = append(animation.Delay, delay)
+	}
+	return gif.EncodeAll(file, animation)
+}
+
+// downsample averages each square of samples by samples pixels into a single pixel.
+func downsample(img *image.RGBA, samples int) *image.RGBA {
+	if samples == 1 {
+		return img
+	}
+	bounds := img.Bounds()
+	result := image.NewRGBA(image.Rect(0, 0, bounds.Dx()/samples, bounds.Dy()/samples))
+	n := samples * samples
+	for y := 0; y < result.Bounds().Dy(); y++ {
+		for x := 0; x < result.Bounds().Dx(); x++ {
+			var sum [4]int
+			for sy := 0; sy < samples; sy++ {
+				for sx := 0; sx < samples; sx++ {
+					i := img.PixOffset(x*samples+sx, y*samples+sy)
+					for c := 0; c < 4; c++ {
+						sum[c] += int(img.Pix[i+c])
+					}
+				}
+			}
+			j := result.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				result.Pix[j+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return result
+}
+
+// parseColor parses a hex color of the form RRGGBB or RRGGBBAA.
+func parseColor(s string) (mgl32.Vec4, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 6 {
+		s += "FF"
+	}
+	if len(s) != 8 {
+		return mgl32.Vec4{}, fmt.Errorf("Invalid color: %s", s)
+	}
+	var c mgl32.Vec4
+	for i := 0; i < 4; i++ {
+		v, err := strconv.ParseUint(s[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return mgl32.Vec4{}, fmt.Errorf("Invalid color: %s", s)
+		}
+		c[i] = float32(v) / 255
+	}
+	return c, nil
+}
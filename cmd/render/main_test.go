@@ -0,0 +1,97 @@
+package main
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "Update golden images")
+
+func TestRenderFrames(t *testing.T) {
+	puzzle := &perspectivefungo.Puzzle{
+		Size:    5,
+		Player:  []int{0, 1, 0},
+		Goal:    []int{-2, -1, 0},
+		Blocks:  []int{0, -2, 0, 1, 1, -1},
+		Portals: []int{2, 2, 2, -2, 2, -2},
+	}
+	start := time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)
+	solution := &perspectivefungo.Solution{
+		Version: perspectivefungo.SOLUTION_VERSION,
+		Start:   start,
+		End:     start.Add(2500 * time.Millisecond),
+		Events: []*perspectivefungo.Event{
+			{Time: start.Add(time.Second), Type: perspectivefungo.RELEASE_BALL, Orientation: &perspectivefungo.Orientation{1, 0, 0, 0, 1, 0, 0, 0, 1}},
+			{Time: start.Add(2 * time.Second), Type: perspectivefungo.RELEASE_BALL, Orientation: &perspectivefungo.Orientation{0, -1, 0, 1, 0, 0, 0, 0, 1}},
+		},
+	}
+	t.Run("Still", func(t *testing.T) {
+		r, err := perspectivefungo.NewReplay(puzzle, solution)
+		assert.Nil(t, err)
+		frames, err := renderFrames(r, CameraPaths["fixed"], 160, 120, 1, 25, 5*time.Second, true)
+		assert.Nil(t, err)
+		assert.Len(t, frames, 1)
+		// The still shows the end of the solution, not the start
+		assert.True(t, r.HasGameEnded())
+		assertGolden(t, "solution.png", frames[0])
+	})
+	t.Run("Animation", func(t *testing.T) {
+		r, err := perspectivefungo.NewReplay(puzzle, solution)
+		assert.Nil(t, err)
+		frames, err := renderFrames(r, CameraPaths["fixed"], 16, 12, 1, 10, time.Second, false)
+		assert.Nil(t, err)
+		assert.Len(t, frames, 10)
+	})
+}
+
+func assertGolden(t *testing.T, name string, actual *image.RGBA) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		assert.Nil(t, os.MkdirAll("testdata", os.ModePerm))
+		f, err := os.Create(path)
+		assert.Nil(t, err)
+		defer f.Close()
+		assert.Nil(t, png.Encode(f, actual))
+		return
+	}
+	f, err := os.Open(path)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer f.Close()
+	expected, err := png.Decode(f)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected.Bounds(), actual.Bounds())
+	// Allow small differences in floating point arithmetic between platforms
+	var mismatched int
+	for y := expected.Bounds().Min.Y; y < expected.Bounds().Max.Y; y++ {
+		for x := expected.Bounds().Min.X; x < expected.Bounds().Max.X; x++ {
+			er, eg, eb, _ := expected.At(x, y).RGBA()
+			ar, ag, ab, _ := actual.At(x, y).RGBA()
+			if differ(er, ar) || differ(eg, ag) || differ(eb, ab) {
+				mismatched++
+			}
+		}
+	}
+	bounds := expected.Bounds()
+	assert.LessOrEqual(t, mismatched, bounds.Dx()*bounds.Dy()/200, "%s differs in %d pixels", name, mismatched)
+}
+
+func differ(a, b uint32) bool {
+	a >>= 8
+	b >>= 8
+	if a > b {
+		return a-b > 8
+	}
+	return b-a > 8
+}
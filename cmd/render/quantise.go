@@ -0,0 +1,150 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+const MAX_PALETTE_SIZE = 256
+
+// box is a group of similar colors which will be represented by a single palette entry.
+type box struct {
+	colors []color.RGBA
+	counts []int
+	total  int
+}
+
+// channel returns the index of the channel with the largest range, and that range.
+func (b *box) channel() (int, int) {
+	var lo, hi [4]uint8
+	for i := range lo {
+		lo[i] = 255
+	}
+	for _, c := range b.colors {
+		for i, v := range [4]uint8{c.R, c.G, c.B, c.A} {
+			if v < lo[i] {
+				lo[i] = v
+			}
+			if v > hi[i] {
+				hi[i] = v
+			}
+		}
+	}
+	var channel, max int
+	for i := range lo {
+		if r := int(hi[i]) - int(lo[i]); r > max {
+			channel, max = i, r
+		}
+	}
+	return channel, max
+}
+
+// split divides the box at the weighted median of its widest channel.
+func (b *box) split() (*box, *box) {
+	channel, _ := b.channel()
+	value := func(c color.RGBA) uint8 {
+		return [4]uint8{c.R, c.G, c.B, c.A}[channel]
+	}
+	indices := make([]int, len(b.colors))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		return value(b.colors[indices[i]]) < value(b.colors[indices[j]])
+	})
+	left, right := &box{}, &box{}
+	half := b.total / 2
+	for i, index := range indices {
+		c, n := b.colors[index], b.counts[index]
+		// Always leave at least one color on each side
+		if (left.total < half && i < len(indices)-1) || i == 0 {
+			left.colors = append(left.colors, c)
+			left.counts = append(left.counts, n)
+			left.total += n
+		} else {
+			right.colors = append(right.colors, c)
+			right.counts = append(right.counts, n)
+			right.total += n
+		}
+	}
+	return left, right
+}
+
+// average returns the mean color of the box, weighted by how often each color occurs.
+func (b *box) average() color.RGBA {
+	var sum [4]int
+	for i, c := range b.colors {
+		n := b.counts[i]
+		sum[0] += int(c.R) * n
+		sum[1] += int(c.G) * n
+		sum[2] += int(c.B) * n
+		sum[3] += int(c.A) * n
+	}
+	return color.RGBA{
+		R: uint8((sum[0] + b.total/2) / b.total),
+		G: uint8((sum[1] + b.total/2) / b.total),
+		B: uint8((sum[2] + b.total/2) / b.total),
+		A: uint8((sum[3] + b.total/2) / b.total),
+	}
+}
+
+// Quantise returns a palette of at most size colors for the given frames using median cut.
+// Frames with no more colors than the size keep every color exactly.
+func Quantise(frames []*image.RGBA, size int) color.Palette {
+	histogram := make(map[color.RGBA]int)
+	for _, f := range frames {
+		for i := 0; i+3 < len(f.Pix); i += 4 {
+			histogram[color.RGBA{f.Pix[i], f.Pix[i+1], f.Pix[i+2], f.Pix[i+3]}]++
+		}
+	}
+	initial := &box{}
+	for c, n := range histogram {
+		initial.colors = append(initial.colors, c)
+		initial.counts = append(initial.counts, n)
+		initial.total += n
+	}
+	if initial.total == 0 {
+		return color.Palette{color.RGBA{}}
+	}
+	boxes := []*box{initial}
+	for len(boxes) < size {
+		// Split the box with the most pixels spread over the widest range
+		best, score := -1, 0
+		for i, b := range boxes {
+			if len(b.colors) < 2 {
+				continue
+			}
+			_, r := b.channel()
+			if s := r * b.total; s > score {
+				best, score = i, s
+			}
+		}
+		if best < 0 {
+			break
+		}
+		left, right := boxes[best].split()
+		boxes[best] = left
+		boxes = append(boxes, right)
+	}
+	var palette color.Palette
+	for _, b := range boxes {
+		palette = append(palette, b.average())
+	}
+	return palette
+}
+
+// Paletted converts the frame to the palette, remembering the nearest entry of each color seen.
+func Paletted(frame *image.RGBA, palette color.Palette, cache map[color.RGBA]uint8) *image.Paletted {
+	img := image.NewPaletted(frame.Bounds(), palette)
+	for i, j := 0, 0; i+3 < len(frame.Pix); i, j = i+4, j+1 {
+		c := color.RGBA{frame.Pix[i], frame.Pix[i+1], frame.Pix[i+2], frame.Pix[i+3]}
+		index, ok := cache[c]
+		if !ok {
+			index = uint8(palette.Index(c))
+			cache[c] = index
+		}
+		img.Pix[j] = index
+	}
+	return img
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestQuantise(t *testing.T) {
+	t.Run("Exact", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 4, 1))
+		colors := []color.RGBA{
+			{255, 255, 255, 255},
+			{255, 0, 0, 255},
+			{0, 204, 0, 255},
+			{0, 0, 204, 230},
+		}
+		for i, c := range colors {
+			img.SetRGBA(i, 0, c)
+		}
+		palette := Quantise([]*image.RGBA{img}, MAX_PALETTE_SIZE)
+		assert.Equal(t, len(colors), len(palette))
+		for _, c := range colors {
+			assert.Contains(t, palette, color.Color(c))
+		}
+		p := Paletted(img, palette, make(map[color.RGBA]uint8))
+		for i, c := range colors {
+			assert.Equal(t, color.Color(c), p.At(i, 0))
+		}
+	})
+	t.Run("Gradient", func(t *testing.T) {
+		// 4096 colors across two frames
+		var frames []*image.RGBA
+		for f := 0; f < 2; f++ {
+			img := image.NewRGBA(image.Rect(0, 0, 64, 32))
+			for y := 0; y < 32; y++ {
+				for x := 0; x < 64; x++ {
+					img.SetRGBA(x, y, color.RGBA{uint8(x * 4), uint8((y + f*32) * 4), 128, 255})
+				}
+			}
+			frames = append(frames, img)
+		}
+		palette := Quantise(frames, MAX_PALETTE_SIZE)
+		assert.Equal(t, MAX_PALETTE_SIZE, len(palette))
+		cache := make(map[color.RGBA]uint8)
+		for _, f := range frames {
+			p := Paletted(f, palette, cache)
+			for y := 0; y < 32; y++ {
+				for x := 0; x < 64; x++ {
+					expected := f.RGBAAt(x, y)
+					actual := p.At(x, y).(color.RGBA)
+					// Each of 256 boxes spans 16 values of red and green
+					assert.InDelta(t, expected.R, actual.R, 16)
+					assert.InDelta(t, expected.G, actual.G, 16)
+					assert.Equal(t, expected.B, actual.B)
+				}
+			}
+		}
+	})
+	t.Run("Empty", func(t *testing.T) {
+		assert.Equal(t, 1, len(Quantise(nil, MAX_PALETTE_SIZE)))
+	})
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+)
+
+// meshScene is a Game which draws a single mesh in the centre of the screen.
+type meshScene struct {
+	mesh       string
+	color      mgl32.Vec4
+	projection mgl32.Mat4
+	camera     mgl32.Mat4
+	model      mgl32.Mat4
+	light      mgl32.Vec3
+}
+
+func newMeshScene(mesh string, color mgl32.Vec4) *meshScene {
+	s := &meshScene{
+		mesh:  mesh,
+		color: color,
+	}
+	s.Reset()
+	return s
+}
+
+func (s *meshScene) Init(d perspectivefungo.Driver) error {
+	return perspectivefungo.LoadAssets(d)
+}
+
+func (s *meshScene) Resize(width, height float32) {
+	s.projection = perspectivefungo.NewProjection(width, height)
+}
+
+func (s *meshScene) Reset() {
+	s.camera = mgl32.LookAtV(perspectivefungo.NewCameraEye(), perspectivefungo.NewCameraLookAt(), perspectivefungo.NewCameraUp())
+	s.model = perspectivefungo.NewModel().Mul4(mgl32.Scale3D(2, 2, 2))
+	s.light = perspectivefungo.NewLight()
+}
+
+func (s *meshScene) Start() {}
+
+func (s *meshScene) Loop(d perspectivefungo.Driver) error {
+	d.SetProjection(&s.projection)
+	d.SetCamera(&s.camera)
+	d.SetLight(&s.light)
+	d.SetColor(&s.color)
+	d.SetModel(&s.model)
+	return d.DrawMesh(s.mesh)
+}
+
+func (s *meshScene) Rotate(float32, float32) {}
+
+func (s *meshScene) RotateToAxis() {}
+
+func (s *meshScene) Turn(int, int) {}
+
+func (s *meshScene) ReleaseBall() {}
+
+func (s *meshScene) Undo() {}
+
+func (s *meshScene) Redo() {}
+
+func (s *meshScene) Hint() {}
+
+func (s *meshScene) HitTest(float32, float32) perspectivefungo.Button {
+	return perspectivefungo.BUTTON_NONE
+}
+
+func (s *meshScene) Animating() bool {
+	return false
+}
+
+func (s *meshScene) Solution() *perspectivefungo.Solution {
+	return nil
+}
+
+func (s *meshScene) GameOver(bool) {}
+
+func (s *meshScene) HasGameStarted() bool {
+	return true
+}
+
+func (s *meshScene) HasGameEnded() bool {
+	return false
+}
+
+// CameraPath returns the transform applied to the camera at a fraction of the way through the recording.
+type CameraPath func(float64) mgl32.Mat4
+
+var CameraPaths = map[string]CameraPath{
+	"fixed": func(float64) mgl32.Mat4 {
+		return mgl32.Ident4()
+	},
+	// Circle the scene once around the vertical axis
+	"orbit": func(f float64) mgl32.Mat4 {
+		return mgl32.HomogRotate3DY(float32(2 * math.Pi * f))
+	},
+	// Tumble the scene once around both the horizontal and vertical axes
+	"spin": func(f float64) mgl32.Mat4 {
+		a := float32(2 * math.Pi * f)
+		return mgl32.HomogRotate3DX(a).Mul4(mgl32.HomogRotate3DY(a))
+	},
+}
+
+// cameraDriver moves the camera set by the game along a path.
+type cameraDriver struct {
+	perspectivefungo.Driver
+	transform mgl32.Mat4
+}
+
+func (d *cameraDriver) SetCamera(c *mgl32.Mat4) {
+	temp := c.Mul4(d.transform)
+	d.Driver.SetCamera(&temp)
+}
+
+// cameraGame draws the game through a cameraDriver.
+type cameraGame struct {
+	perspectivefungo.Game
+	driver cameraDriver
+}
+
+func (g *cameraGame) Loop(d perspectivefungo.Driver) error {
+	g.driver.Driver = d
+	return g.Game.Loop(&g.driver)
+}
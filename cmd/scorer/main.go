@@ -8,6 +8,10 @@ import (
 	"os"
 )
 
+var (
+	route = flag.String("route", "", "Write the optimal route to file")
+)
+
 func main() {
 	flag.Parse()
 
@@ -27,8 +31,29 @@ func main() {
 	if err := json.NewDecoder(reader).Decode(&p); err != nil {
 		log.Fatal(err)
 	}
+	if err := p.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	r, err := perspectivefungo.Solve(&p)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Rotations:", r.Rotations)
+	log.Println("Penalties:", r.Penalties)
+	for i, m := range r.Moves {
+		log.Println("Move:", i+1, "Direction:", m.Direction, "Cell:", m.Cell)
+	}
 
-	rotations, penalties := perspectivefungo.Score(&p)
-	log.Println("Rotations:", rotations)
-	log.Println("Penalties:", penalties)
+	if *route != "" {
+		log.Println("Writing:", *route)
+		file, err := os.Create(*route)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		if err := json.NewEncoder(file).Encode(r); err != nil {
+			log.Fatal(err)
+		}
+	}
 }
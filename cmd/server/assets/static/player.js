@@ -28,3 +28,21 @@ function play(puzzle) {
 function dismiss() {
     document.getElementById("instructions").style.display = "none";
 }
+
+function submitSolution(solution) {
+    fetch("daily/solution", {
+        method: "POST",
+        headers: {
+            "Content-Type": "application/json",
+        },
+        body: solution,
+    })
+        .then((result) => {
+            if (!result.ok) {
+                console.warn(result.status, result.statusText);
+            }
+        })
+        .catch((error) => {
+            console.warn(error);
+        });
+}
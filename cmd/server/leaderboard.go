@@ -0,0 +1,224 @@
+package main
+
+import (
+	"aletheiaware.com/netgo/handler"
+	"aletheiaware.com/perspectivefungo"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LeaderboardStore keeps one file per date containing that day's leaderboard.
+type LeaderboardStore struct {
+	sync.Mutex
+	directory string
+}
+
+func NewLeaderboardStore(directory string) *LeaderboardStore {
+	return &LeaderboardStore{
+		directory: directory,
+	}
+}
+
+// Get returns the leaderboard for the given date, or an empty leaderboard if nobody has solved it yet.
+func (s *LeaderboardStore) Get(date time.Time) (*perspectivefungo.Leaderboard, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.read(date)
+}
+
+// Add records a solve by the player on the leaderboard for the given date.
+func (s *LeaderboardStore) Add(date time.Time, player string, duration time.Duration, rotations, undos uint) error {
+	s.Lock()
+	defer s.Unlock()
+	l, err := s.read(date)
+	if err != nil {
+		return err
+	}
+	l.Add(player, duration, rotations, undos)
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	name := s.filename(date)
+	temp := name + ".tmp"
+	if err := os.WriteFile(temp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, name)
+}
+
+// Dates returns the dates which have a leaderboard, most recent first.
+func (s *LeaderboardStore) Dates() ([]time.Time, error) {
+	files, err := filepath.Glob(filepath.Join(s.directory, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var dates []time.Time
+	for _, f := range files {
+		d, err := time.Parse(perspectivefungo.DATE_FORMAT, strings.TrimSuffix(filepath.Base(f), ".json"))
+		if err != nil {
+			continue
+		}
+		dates = append(dates, d)
+	}
+	sort.Slice(dates, func(i, j int) bool {
+		return dates[i].After(dates[j])
+	})
+	return dates, nil
+}
+
+func (s *LeaderboardStore) filename(date time.Time) string {
+	return filepath.Join(s.directory, perspectivefungo.DailyFilename(date))
+}
+
+func (s *LeaderboardStore) read(date time.Time) (*perspectivefungo.Leaderboard, error) {
+	l := perspectivefungo.NewLeaderboard(date)
+	data, err := os.ReadFile(s.filename(date))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return l, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// AttachLeaderboardHandlers serves the leaderboard for the date given by the query, defaulting to today, and an archive of past leaderboards.
+func AttachLeaderboardHandlers(mux *http.ServeMux, store *LeaderboardStore, templates *template.Template) {
+	mux.Handle("/daily/leaderboard.json", handler.Log(handler.Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l, err := leaderboard(store, r)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Players are only needed to count each once
+		l.Players = nil
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(l); err != nil {
+			log.Println(err)
+			return
+		}
+	}))))
+
+	mux.Handle("/daily/leaderboard", handler.Log(handler.Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l, err := leaderboard(store, r)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data := struct {
+			Leaderboard *perspectivefungo.Leaderboard
+			Histogram   []*Bar
+		}{
+			Leaderboard: l,
+			Histogram:   histogram(l),
+		}
+		if err := templates.ExecuteTemplate(w, "leaderboard.go.html", data); err != nil {
+			log.Println(err)
+			return
+		}
+	}))))
+
+	mux.Handle("/daily/archive", handler.Log(handler.Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dates, err := store.Dates()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		today := perspectivefungo.Today()
+		var leaderboards []*perspectivefungo.Leaderboard
+		for _, d := range dates {
+			if !d.Before(today) {
+				continue
+			}
+			l, err := store.Get(d)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			leaderboards = append(leaderboards, l)
+		}
+		data := struct {
+			Leaderboards []*perspectivefungo.Leaderboard
+		}{
+			Leaderboards: leaderboards,
+		}
+		if err := templates.ExecuteTemplate(w, "archive.go.html", data); err != nil {
+			log.Println(err)
+			return
+		}
+	}))))
+}
+
+func leaderboard(store *LeaderboardStore, r *http.Request) (*perspectivefungo.Leaderboard, error) {
+	date := perspectivefungo.Today()
+	if d := r.URL.Query().Get("date"); d != "" {
+		var err error
+		date, err = time.Parse(perspectivefungo.DATE_FORMAT, d)
+		if err != nil {
+			return nil, err
+		}
+		if date.After(perspectivefungo.Today()) {
+			return nil, errors.New("Future Date")
+		}
+	}
+	return store.Get(date)
+}
+
+// Bar is one bucket of the solve time histogram, with its height as a percentage of the largest bucket.
+type Bar struct {
+	Label   string
+	Count   uint
+	Percent uint
+}
+
+func histogram(l *perspectivefungo.Leaderboard) []*Bar {
+	var max uint
+	for _, c := range l.Histogram {
+		if c > max {
+			max = c
+		}
+	}
+	var bars []*Bar
+	for i, c := range l.Histogram {
+		b := &Bar{
+			Count: c,
+		}
+		if i < len(perspectivefungo.HistogramBuckets) {
+			b.Label = "≤ " + perspectivefungo.HistogramBuckets[i].String()
+		} else {
+			b.Label = "> " + perspectivefungo.HistogramBuckets[len(perspectivefungo.HistogramBuckets)-1].String()
+		}
+		if max > 0 {
+			b.Percent = c * 100 / max
+		}
+		bars = append(bars, b)
+	}
+	return bars
+}
+
+// solved returns the number of players who have solved today's puzzle.
+func solved(store *LeaderboardStore) uint {
+	l, err := store.Get(perspectivefungo.Today())
+	if err != nil {
+		log.Println(err)
+		return 0
+	}
+	return l.Solved
+}
@@ -3,9 +3,12 @@ package main
 import (
 	"aletheiaware.com/netgo"
 	"aletheiaware.com/netgo/handler"
+	"aletheiaware.com/perspectivefungo"
 	"crypto/tls"
 	"embed"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"html/template"
 	"io/fs"
 	"log"
@@ -46,13 +49,29 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	templates, err := template.ParseFS(templateFS, "*.go.html")
+	templates, err := template.New("").Funcs(template.FuncMap{
+		"inc": func(i int) int {
+			return i + 1
+		},
+		"seconds": func(d time.Duration) string {
+			return fmt.Sprintf("%.2fs", d.Seconds())
+		},
+	}).ParseFS(templateFS, "*.go.html")
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	AttachAssetHandlers(mux)
 
+	// Absolute URLs are built from the configured host, never the request's Host header
+	host, ok := os.LookupEnv("HOST")
+	if !ok {
+		if netgo.IsSecure() {
+			log.Fatal(errors.New("Missing HOST environment variable"))
+		}
+		host = "localhost"
+	}
+
 	puzzles, ok := os.LookupEnv("PUZZLE_DIRECTORY")
 	if !ok {
 		puzzles = "puzzles"
@@ -61,16 +80,90 @@ func main() {
 		log.Fatal(err)
 	}
 	log.Println("Puzzles Directory:", puzzles)
+	log.Println("Puzzles Scheduled:", perspectivefungo.CoveredDays(puzzles, perspectivefungo.Today()), "days")
+
+	if s, ok := os.LookupEnv("PUZZLE_SCHEDULER"); ok && s == "true" {
+		pool := os.Getenv("PUZZLE_POOL_DIRECTORY")
+		if pool != "" {
+			log.Println("Puzzle Pool Directory:", pool)
+		}
+		go schedule(puzzles, pool)
+	}
+
+	solutions, ok := os.LookupEnv("SOLUTION_DIRECTORY")
+	if !ok {
+		solutions = "solutions"
+	}
+	if err := os.MkdirAll(solutions, os.ModePerm); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Solutions Directory:", solutions)
+
+	leaderboards, ok := os.LookupEnv("LEADERBOARD_DIRECTORY")
+	if !ok {
+		leaderboards = "leaderboards"
+	}
+	if err := os.MkdirAll(leaderboards, os.ModePerm); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Leaderboards Directory:", leaderboards)
+
+	store := NewLeaderboardStore(leaderboards)
+
+	AttachSolutionHandler(mux, puzzles, solutions, store)
+
+	AttachLeaderboardHandlers(mux, store, templates)
+
+	previews, ok := os.LookupEnv("PREVIEW_DIRECTORY")
+	if !ok {
+		previews = "previews"
+	}
+	if err := os.MkdirAll(previews, os.ModePerm); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Previews Directory:", previews)
+
+	AttachPreviewHandlers(mux, NewPreviewCache(puzzles, previews))
 
 	mux.Handle("/daily.json", handler.Log(handler.Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, path.Join(puzzles, time.Now().UTC().Format("2006-01-02")+".json"))
+		name := path.Join(puzzles, perspectivefungo.DailyFilename(time.Now()))
+		data, err := os.ReadFile(name)
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, fs.ErrNotExist) {
+				http.NotFound(w, r)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		var puzzle perspectivefungo.Puzzle
+		if err := json.Unmarshal(data, &puzzle); err != nil {
+			log.Println("Invalid Puzzle:", name, err)
+			http.Error(w, "Invalid Puzzle", http.StatusInternalServerError)
+			return
+		}
+		if err := puzzle.Validate(); err != nil {
+			log.Println("Invalid Puzzle:", name, err)
+			http.Error(w, "Invalid Puzzle", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(data); err != nil {
+			log.Println(err)
+			return
+		}
 	}))))
 
 	mux.Handle("/daily", handler.Log(handler.Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		data := struct {
-			Live bool
+			Live    bool
+			Solved  uint
+			Preview string
 		}{
-			Live: netgo.IsLive(),
+			Live:    netgo.IsLive(),
+			Solved:  solved(store),
+			Preview: previewURL(host, perspectivefungo.Today()),
 		}
 		if err := templates.ExecuteTemplate(w, "daily.go.html", data); err != nil {
 			log.Println(err)
@@ -101,11 +194,15 @@ func main() {
 		}
 		netgo.LogRequest(r)
 		data := struct {
-			Live bool
-			Date string
+			Live    bool
+			Date    string
+			Solved  uint
+			Preview string
 		}{
-			Live: netgo.IsLive(),
-			Date: time.Now().UTC().Format("2006-01-02"),
+			Live:    netgo.IsLive(),
+			Date:    time.Now().UTC().Format(perspectivefungo.DATE_FORMAT),
+			Solved:  solved(store),
+			Preview: previewURL(host, perspectivefungo.Today()),
 		}
 		if err := templates.ExecuteTemplate(w, "index.go.html", data); err != nil {
 			log.Println(err)
@@ -114,11 +211,6 @@ func main() {
 	})))
 
 	if netgo.IsSecure() {
-		host, ok := os.LookupEnv("HOST")
-		if !ok {
-			log.Fatal(errors.New("Missing HOST environment variable"))
-		}
-
 		routeMap := make(map[string]bool)
 
 		routes, ok := os.LookupEnv("ROUTES")
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"aletheiaware.com/netgo"
+	"aletheiaware.com/netgo/handler"
+	"aletheiaware.com/perspectivefungo"
+	"aletheiaware.com/perspectivefungo/headless"
+	"bytes"
+	"errors"
+	"image/png"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PREVIEW_WIDTH  = 1200
+	PREVIEW_HEIGHT = 630
+)
+
+// PreviewCache renders puzzles to images, keeping them on disk until the puzzle changes.
+type PreviewCache struct {
+	sync.Mutex
+	puzzles   string
+	directory string
+}
+
+func NewPreviewCache(puzzles, directory string) *PreviewCache {
+	return &PreviewCache{
+		puzzles:   puzzles,
+		directory: directory,
+	}
+}
+
+// Get returns the preview of the puzzle for the given date, rendering it if it is missing or older than the puzzle.
+func (c *PreviewCache) Get(date time.Time) ([]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+	name := filepath.Join(c.puzzles, perspectivefungo.DailyFilename(date))
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	preview := filepath.Join(c.directory, strings.TrimSuffix(filepath.Base(name), ".json")+".png")
+	if cached, err := os.Stat(preview); err == nil && !cached.ModTime().Before(info.ModTime()) {
+		return os.ReadFile(preview)
+	}
+	puzzle, err := perspectivefungo.ReadPuzzle(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := puzzle.Validate(); err != nil {
+		return nil, err
+	}
+	data, err := renderPreview(puzzle)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Rendered Preview:", preview)
+	temp := preview + ".tmp"
+	if err := os.WriteFile(temp, data, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(temp, preview); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// renderPreview draws the puzzle from the default camera as it appears once the game has started.
+func renderPreview(puzzle *perspectivefungo.Puzzle) ([]byte, error) {
+	d := headless.NewDriver(PREVIEW_WIDTH, PREVIEW_HEIGHT)
+	g := perspectivefungo.NewGame(puzzle)
+	if err := d.Init(g); err != nil {
+		return nil, err
+	}
+	g.Start()
+	if err := d.Loop(0); err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, d.Image()); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// AttachPreviewHandlers serves the preview of today's puzzle, and of past puzzles by date.
+func AttachPreviewHandlers(mux *http.ServeMux, cache *PreviewCache) {
+	serve := func(w http.ResponseWriter, r *http.Request, date time.Time) {
+		if date.After(perspectivefungo.Today()) {
+			// Don't reveal future puzzles
+			http.NotFound(w, r)
+			return
+		}
+		data, err := cache.Get(date)
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, fs.ErrNotExist) {
+				http.NotFound(w, r)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		if _, err := w.Write(data); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+
+	mux.Handle("/daily.png", handler.Log(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serve(w, r, perspectivefungo.Today())
+	})))
+
+	mux.Handle("/puzzle/", handler.Log(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/puzzle/")
+		if !strings.HasSuffix(name, ".png") {
+			http.NotFound(w, r)
+			return
+		}
+		date, err := time.Parse(perspectivefungo.DATE_FORMAT, strings.TrimSuffix(name, ".png"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		serve(w, r, date)
+	})))
+}
+
+// previewURL returns the absolute URL on the host of the preview for the given date, as required by Open Graph.
+func previewURL(host string, date time.Time) string {
+	scheme := "http"
+	if netgo.IsSecure() {
+		scheme = "https"
+	}
+	return scheme + "://" + host + "/puzzle/" + date.UTC().Format(perspectivefungo.DATE_FORMAT) + ".png"
+}
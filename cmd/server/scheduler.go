@@ -0,0 +1,98 @@
+package main
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	SCHEDULE_INTERVAL = time.Hour
+	GENERATE_TIMEOUT  = 30 * time.Minute
+)
+
+// schedule periodically ensures today and tomorrow have a puzzle, promoting one from the pool directory if possible, otherwise generating one.
+func schedule(puzzles, pool string) {
+	for {
+		today := perspectivefungo.Today()
+		for _, date := range []time.Time{today, today.AddDate(0, 0, 1)} {
+			name := filepath.Join(puzzles, perspectivefungo.DailyFilename(date))
+			if _, err := os.Stat(name); err == nil {
+				continue
+			}
+			if err := scheduleDay(name, pool, date); err != nil {
+				log.Println("Failed to schedule puzzle:", name, err)
+			}
+		}
+		log.Println("Puzzles Scheduled:", perspectivefungo.CoveredDays(puzzles, today), "days")
+		time.Sleep(SCHEDULE_INTERVAL)
+	}
+}
+
+func scheduleDay(name, pool string, date time.Time) error {
+	if pool != "" {
+		promoted, err := promote(name, pool, date)
+		if err != nil {
+			return err
+		}
+		if promoted {
+			return nil
+		}
+	}
+	log.Println("Generating:", name)
+	ctx, cancel := context.WithTimeout(context.Background(), GENERATE_TIMEOUT)
+	defer cancel()
+	// Use a single worker to avoid starving the server
+	p, err := perspectivefungo.GenerateDaily(ctx, date, time.Now().UnixNano(), 1, nil)
+	if err != nil {
+		return err
+	}
+	log.Println("Generated:", name, "Seed:", *p.Seed)
+	return perspectivefungo.WritePuzzle(name, p)
+}
+
+// promote moves a valid puzzle from the pool to the given name, preferring puzzles matching the size of the date's difficulty, and returns false if the pool has no valid puzzles.
+func promote(name, pool string, date time.Time) (bool, error) {
+	files, err := filepath.Glob(filepath.Join(pool, "*.json"))
+	if err != nil {
+		return false, err
+	}
+	sort.Strings(files)
+	var (
+		candidate string
+		puzzle    *perspectivefungo.Puzzle
+	)
+	size := perspectivefungo.Difficulties[date.Weekday()].Size
+	for _, f := range files {
+		p, err := perspectivefungo.ReadPuzzle(f)
+		if err == nil {
+			err = p.Validate()
+		}
+		if err != nil {
+			log.Println("Invalid Puzzle:", f, err)
+			continue
+		}
+		if puzzle == nil || (puzzle.Size != size && p.Size == size) {
+			candidate = f
+			puzzle = p
+		}
+		if p.Size == size {
+			break
+		}
+	}
+	if puzzle == nil {
+		return false, nil
+	}
+	log.Println("Promoting:", candidate, "to", name)
+	if err := perspectivefungo.WritePuzzle(name, puzzle); err != nil {
+		return false, err
+	}
+	if err := os.Remove(candidate); err != nil {
+		return false, err
+	}
+	return true, nil
+}
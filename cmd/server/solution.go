@@ -0,0 +1,170 @@
+package main
+
+import (
+	"aletheiaware.com/netgo"
+	"aletheiaware.com/netgo/handler"
+	"aletheiaware.com/perspectivefungo"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const MAX_SOLUTION_SIZE = 1 << 20
+
+// Cookie identifying the player, so the leaderboard counts each player once however many solutions they submit
+const PLAYER_COOKIE = "player"
+
+// AttachSolutionHandler accepts solutions to recent daily puzzles, storing them by date and adding them to the leaderboard if they reach the goal.
+// Solutions are named by their hash, so the same solution can only be added once.
+func AttachSolutionHandler(mux *http.ServeMux, puzzles, solutions string, leaderboards *LeaderboardStore) {
+	mux.Handle("/daily/solution", handler.Log(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		// Identify the player before anything is written, as the cookie is set in the header
+		player, err := playerID(w, r)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_SOLUTION_SIZE))
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Invalid Solution", http.StatusBadRequest)
+			return
+		}
+		var solution perspectivefungo.Solution
+		if err := json.Unmarshal(data, &solution); err != nil {
+			log.Println(err)
+			http.Error(w, "Invalid Solution", http.StatusBadRequest)
+			return
+		}
+		if solution.Version == 0 {
+			// Snapshots alone don't show how the solution was played
+			log.Println("Unsupported Solution Version:", solution.Version)
+			http.Error(w, "Unsupported Solution Version", http.StatusBadRequest)
+			return
+		}
+		// Allow a puzzle started before midnight to be finished after
+		date := solution.Start.UTC().Truncate(24 * time.Hour)
+		today := perspectivefungo.Today()
+		if date.After(today) || date.Before(today.AddDate(0, 0, -1)) {
+			log.Println("Expired Solution:", date.Format(perspectivefungo.DATE_FORMAT))
+			http.Error(w, "Expired Solution", http.StatusBadRequest)
+			return
+		}
+		name := filepath.Join(puzzles, perspectivefungo.DailyFilename(date))
+		puzzle, err := perspectivefungo.ReadPuzzle(name)
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, fs.ErrNotExist) {
+				http.NotFound(w, r)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		if err := puzzle.Validate(); err != nil {
+			log.Println("Invalid Puzzle:", name, err)
+			http.Error(w, "Invalid Puzzle", http.StatusInternalServerError)
+			return
+		}
+		route, err := solution.Replay(puzzle)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Hash the encoding rather than the body, so changes to whitespace or field order still count as the same solution
+		data, err = json.Marshal(&solution)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		hash := sha256.Sum256(data)
+		file := filepath.Join(solutions, date.Format(perspectivefungo.DATE_FORMAT), hex.EncodeToString(hash[:])+".json")
+		if err := storeSolution(file, data); err != nil {
+			log.Println(err)
+			if errors.Is(err, fs.ErrExist) {
+				http.Error(w, "Duplicate Solution", http.StatusConflict)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		duration := solution.End.Sub(solution.Start)
+		if err := leaderboards.Add(date, player, duration, route.Rotations, solution.Undos()); err != nil {
+			log.Println(err)
+			// Allow the solution to be submitted again
+			os.Remove(file)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Println("Solution Accepted:", date.Format(perspectivefungo.DATE_FORMAT), duration, route.Rotations)
+		w.WriteHeader(http.StatusCreated)
+	})))
+}
+
+// playerID returns a hash of the ID in the player cookie, first giving the player a new random ID if they don't have one.
+// The hash is stored rather than the ID, so the leaderboard never holds a value that could be used to impersonate a player.
+func playerID(w http.ResponseWriter, r *http.Request) (string, error) {
+	var id string
+	if c, err := r.Cookie(PLAYER_COOKIE); err == nil && c.Value != "" {
+		id = c.Value
+	} else {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		id = hex.EncodeToString(b)
+		http.SetCookie(w, &http.Cookie{
+			Name:     PLAYER_COOKIE,
+			Value:    id,
+			Path:     "/",
+			MaxAge:   365 * 24 * 60 * 60,
+			Secure:   netgo.IsSecure(),
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+	hash := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// storeSolution writes the solution to a temporary file and links it into place once complete, so readers never see a partial solution.
+// An error wrapping fs.ErrExist is returned if a file with the given name already exists.
+func storeSolution(name string, data []byte) error {
+	directory := filepath.Dir(name)
+	if err := os.MkdirAll(directory, os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(directory, "*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	// Unlike rename, link fails rather than replacing an existing file
+	defer os.Remove(f.Name())
+	return os.Link(f.Name(), name)
+}
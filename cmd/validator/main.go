@@ -0,0 +1,69 @@
+package main
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"encoding/json"
+	"flag"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	flag.Parse()
+
+	directory, ok := os.LookupEnv("PUZZLE_DIRECTORY")
+	if !ok {
+		directory = "puzzles"
+	}
+	if args := flag.Args(); len(args) > 0 {
+		directory = args[0]
+	}
+	log.Println("Validating:", directory)
+
+	var valid, invalid int
+	if err := filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		if err := validate(path); err != nil {
+			invalid++
+			log.Println("Invalid:", path)
+			if errs, ok := err.(perspectivefungo.ValidationErrors); ok {
+				for _, e := range errs {
+					log.Printf("\t%v", e)
+				}
+			} else {
+				log.Printf("\t%v", err)
+			}
+		} else {
+			valid++
+		}
+		return nil
+	}); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Valid:", valid)
+	log.Println("Invalid:", invalid)
+	if invalid > 0 {
+		os.Exit(1)
+	}
+}
+
+func validate(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var p perspectivefungo.Puzzle
+	if err := json.NewDecoder(file).Decode(&p); err != nil {
+		return err
+	}
+	return p.Validate()
+}
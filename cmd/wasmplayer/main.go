@@ -2,28 +2,27 @@ package main
 
 import (
 	"aletheiaware.com/perspectivefungo"
+	"encoding/json"
 	"fmt"
 	"log"
-	"math"
 	"net/url"
 	"strconv"
+	"strings"
 	"syscall/js"
 )
 
 var (
-	window       js.Value
-	document     js.Value
-	canvas       js.Value
-	gl           js.Value
-	d            *driver
-	g            perspectivefungo.Game
-	width        float64
-	height       float64
-	scale        float64
-	threshold    float64
-	dragging     bool
-	rotated      bool
-	lastX, lastY float64
+	window    js.Value
+	document  js.Value
+	canvas    js.Value
+	gl        js.Value
+	d         *driver
+	g         perspectivefungo.Game
+	input     *perspectivefungo.InputController
+	bindings  perspectivefungo.KeyBindings
+	width     float64
+	height    float64
+	submitted bool
 )
 
 func main() {
@@ -49,6 +48,9 @@ func main() {
 	canvas.Call("addEventListener", "pointermove", js.FuncOf(handleMove))
 	canvas.Call("addEventListener", "pointerup", js.FuncOf(handleUp))
 
+	bindings = loadKeyBindings()
+	document.Call("addEventListener", "keydown", js.FuncOf(handleKey))
+
 	gl = canvas.Call("getContext", "webgl", "{antialias: true}")
 	if gl.IsUndefined() {
 		js.Global().Call("alert", "Your browser doesn't appear to support WebGL")
@@ -61,14 +63,11 @@ func main() {
 		canvas.Call("setAttribute", "width", width)
 		canvas.Call("setAttribute", "height", height)
 
-		if g != nil {
-			g.Resize(float32(width), float32(height))
+		if input != nil {
+			input.Resize(width, height)
 		}
 
 		gl.Call("viewport", 0, 0, width, height)
-
-		scale = math.Min(width, height)
-		threshold = scale / 100
 	}
 
 	window.Call("addEventListener", "resize", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
@@ -88,6 +87,14 @@ func renderFrame(now float64) {
 		log.Fatal(err)
 	}
 
+	if g != nil && g.HasGameEnded() != submitted {
+		// Submit once per win, and again after a retry
+		submitted = g.HasGameEnded()
+		if s := g.Solution(); submitted && s != nil {
+			submitSolution(s)
+		}
+	}
+
 	loop()
 }
 
@@ -121,6 +128,13 @@ func loadPuzzle(data js.Value) error {
 		}
 	}
 
+	crumbling := data.Get("crumbling")
+	if !crumbling.IsUndefined() && !crumbling.IsNull() {
+		for i := 0; i < crumbling.Get("length").Int(); i++ {
+			puzzle.Crumbling = append(puzzle.Crumbling, crumbling.Get(strconv.Itoa(i)).Int())
+		}
+	}
+
 	portals := data.Get("portals")
 	if !portals.IsUndefined() && !portals.IsNull() {
 		for i := 0; i < portals.Get("length").Int(); i++ {
@@ -128,13 +142,82 @@ func loadPuzzle(data js.Value) error {
 		}
 	}
 
+	links := data.Get("links")
+	if !links.IsUndefined() && !links.IsNull() {
+		for i := 0; i < links.Get("length").Int(); i++ {
+			link := links.Get(strconv.Itoa(i))
+			l := &perspectivefungo.Link{
+				Type: perspectivefungo.LinkType(link.Get("type").String()),
+			}
+			from := link.Get("from")
+			to := link.Get("to")
+			if from.IsUndefined() || from.IsNull() || to.IsUndefined() || to.IsNull() {
+				return fmt.Errorf("Invalid link %d", i)
+			}
+			for j := 0; j < 3; j++ {
+				l.From[j] = from.Get(strconv.Itoa(j)).Int()
+				l.To[j] = to.Get(strconv.Itoa(j)).Int()
+			}
+			puzzle.Links = append(puzzle.Links, l)
+		}
+	}
+
+	switches := data.Get("switches")
+	if !switches.IsUndefined() && !switches.IsNull() {
+		for i := 0; i < switches.Get("length").Int(); i++ {
+			s := switches.Get(strconv.Itoa(i))
+			cell := s.Get("cell")
+			direction := s.Get("direction")
+			if cell.IsUndefined() || cell.IsNull() || direction.IsUndefined() || direction.IsNull() {
+				return fmt.Errorf("Invalid switch %d", i)
+			}
+			sw := &perspectivefungo.GravitySwitch{}
+			for j := 0; j < 3; j++ {
+				sw.Cell[j] = cell.Get(strconv.Itoa(j)).Int()
+				sw.Direction[j] = direction.Get(strconv.Itoa(j)).Int()
+			}
+			puzzle.Switches = append(puzzle.Switches, sw)
+		}
+	}
+
+	locks := data.Get("locks")
+	if !locks.IsUndefined() && !locks.IsNull() {
+		for i := 0; i < locks.Get("length").Int(); i++ {
+			lock := locks.Get(strconv.Itoa(i))
+			key := lock.Get("key")
+			doors := lock.Get("doors")
+			if key.IsUndefined() || key.IsNull() || doors.IsUndefined() || doors.IsNull() {
+				return fmt.Errorf("Invalid lock %d", i)
+			}
+			l := &perspectivefungo.Lock{}
+			for j := 0; j < 3; j++ {
+				l.Key[j] = key.Get(strconv.Itoa(j)).Int()
+			}
+			for j := 0; j < doors.Get("length").Int(); j++ {
+				door := doors.Get(strconv.Itoa(j))
+				var cell [3]int
+				for k := 0; k < 3; k++ {
+					cell[k] = door.Get(strconv.Itoa(k)).Int()
+				}
+				l.Doors = append(l.Doors, cell)
+			}
+			puzzle.Locks = append(puzzle.Locks, l)
+		}
+	}
+
+	if err := puzzle.Validate(); err != nil {
+		return err
+	}
+
 	g = perspectivefungo.NewGame(puzzle)
+	input = perspectivefungo.NewInputController(g, bindings)
+	input.OnShare = share
 
 	if err := d.Init(g); err != nil {
 		return err
 	}
 
-	g.Resize(float32(width), float32(height))
+	input.Resize(width, height)
 
 	gl.Call("viewport", 0, 0, width, height)
 
@@ -142,102 +225,112 @@ func loadPuzzle(data js.Value) error {
 	return nil
 }
 
-func handleDown(this js.Value, args []js.Value) interface{} {
-	event := args[0]
-	if !event.Get("isPrimary").Bool() {
-		// Ignore
+// submitSolution passes the encoded solution to Javascript to be posted to the server.
+func submitSolution(s *perspectivefungo.Solution) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	js.Global().Call("submitSolution", string(data))
+}
+
+// loadKeyBindings reads any bindings stored by the page under keyBindings, falling back to the defaults.
+func loadKeyBindings() perspectivefungo.KeyBindings {
+	stored := window.Get("localStorage").Call("getItem", "keyBindings")
+	if stored.IsNull() || stored.IsUndefined() {
+		return perspectivefungo.DefaultKeyBindings()
+	}
+	b, err := perspectivefungo.LoadKeyBindings(strings.NewReader(stored.String()))
+	if err != nil {
+		log.Println(err)
+		return perspectivefungo.DefaultKeyBindings()
+	}
+	return b
+}
+
+func handleKey(this js.Value, args []js.Value) interface{} {
+	if input == nil {
 		return nil
 	}
-	if g != nil && g.Animating() {
+	event := args[0]
+	if event.Get("ctrlKey").Bool() || event.Get("metaKey").Bool() || event.Get("altKey").Bool() {
+		// Leave browser shortcuts alone
 		return nil
 	}
-	x := event.Get("clientX").Float()
-	y := event.Get("clientY").Float()
-	dragging = true
-	rotated = false
-	lastX, lastY = x, y
+	if input.Key(keyName(event.Get("key").String())) {
+		// Stop arrows and space from scrolling the page
+		event.Call("preventDefault")
+	}
 	return nil
 }
 
-func handleCancel(this js.Value, args []js.Value) interface{} {
+// keyName returns the name of the key as used by KeyBindings.
+func keyName(key string) string {
+	switch key {
+	case " ":
+		return "space"
+	case "Enter":
+		return "enter"
+	case "Escape":
+		return "escape"
+	case "ArrowUp":
+		return "up"
+	case "ArrowDown":
+		return "down"
+	case "ArrowLeft":
+		return "left"
+	case "ArrowRight":
+		return "right"
+	}
+	return strings.ToLower(key)
+}
+
+func handleDown(this js.Value, args []js.Value) interface{} {
 	event := args[0]
-	if !event.Get("isPrimary").Bool() {
+	if !event.Get("isPrimary").Bool() || input == nil {
 		// Ignore
 		return nil
 	}
-	if g != nil && g.Animating() {
+	input.Down(event.Get("clientX").Float(), event.Get("clientY").Float())
+	return nil
+}
+
+func handleCancel(this js.Value, args []js.Value) interface{} {
+	event := args[0]
+	if !event.Get("isPrimary").Bool() || input == nil {
+		// Ignore
 		return nil
 	}
-	dragging = false
-	rotated = false
-	g.RotateToAxis()
+	input.Cancel()
 	return nil
 }
 
 func handleMove(this js.Value, args []js.Value) interface{} {
 	event := args[0]
-	if !event.Get("isPrimary").Bool() {
+	if !event.Get("isPrimary").Bool() || input == nil {
 		// Ignore
 		return nil
 	}
-	if g != nil && g.Animating() {
-		return nil
-	}
-	x := event.Get("clientX").Float()
-	y := event.Get("clientY").Float()
-	if dragging {
-		deltaX := x - lastX
-		deltaY := y - lastY
-		if math.Abs(deltaX) > threshold || math.Abs(deltaY) > threshold {
-			rotated = true
-			radX := float32((deltaY / scale) * 2.0 * math.Pi)
-			radY := float32((deltaX / scale) * 2.0 * math.Pi)
-			g.Rotate(radX, radY)
-		} else {
-			// Don't update lastX or lastY
-			return nil
-		}
-	}
-	lastX, lastY = x, y
+	input.Move(event.Get("clientX").Float(), event.Get("clientY").Float())
 	return nil
 }
 
 func handleUp(this js.Value, args []js.Value) interface{} {
 	event := args[0]
-	if !event.Get("isPrimary").Bool() {
+	if !event.Get("isPrimary").Bool() || input == nil {
 		// Ignore
 		return nil
 	}
-	if g != nil && g.Animating() {
-		return nil
-	}
-	dragging = false
-	if rotated {
-		g.RotateToAxis()
-	} else if !g.HasGameStarted() {
-		g.Start()
-	} else if g.HasGameEnded() {
-		s := g.Solution()
-		if s == nil {
-			g.Reset()
-			g.Start()
-		} else {
-			x := event.Get("clientX").Float()
-			if x < width/2 {
-				g.Reset()
-				g.Start()
-			} else {
-				// TODO write solution to storage
-				params := url.Values{}
-				params.Add("url", "https://perspective.fun/daily")
-				params.Add("text", fmt.Sprintf("%.2fs\n\n%s\n\n", s.End.Sub(s.Start).Seconds(), s.Start.UTC().Format("2006-01-02")))
-				params.Add("hashtags", "PerspectiveDailyPuzzle")
-				window.Get("location").Set("href", "https://twitter.com/intent/tweet?"+params.Encode())
-			}
-		}
-	} else {
-		g.ReleaseBall()
-	}
-	rotated = false
+	input.Up(event.Get("clientX").Float(), event.Get("clientY").Float())
 	return nil
 }
+
+// share opens a tweet of the time taken to solve the puzzle.
+func share(s *perspectivefungo.Solution) {
+	params := url.Values{}
+	params.Add("url", "https://perspective.fun/daily")
+	params.Add("text", fmt.Sprintf("%.2fs\n\n%s\n\n", s.End.Sub(s.Start).Seconds(), s.Start.UTC().Format("2006-01-02")))
+	params.Add("hashtags", "PerspectiveDailyPuzzle")
+	window.Get("location").Set("href", "https://twitter.com/intent/tweet?"+params.Encode())
+}
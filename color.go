@@ -11,11 +11,33 @@ var (
 	RetryColor      = mgl32.Vec4{0.9, 0, 0, 1}
 	ShareColor      = mgl32.Vec4{0, 0.8, 0, 1}
 	BlockColor      = mgl32.Vec4{0.5, 0.5, 0.5, 1}
+	CrumblingColor  = mgl32.Vec4{0.7, 0.55, 0.35, 1}
 	GoalColor       = mgl32.Vec4{0, 0.8, 0, 0.9}
 	PlayerColor     = mgl32.Vec4{1, 1, 0, 1}
-	PortalColors    = []mgl32.Vec4{
+	HintColor       = mgl32.Vec4{1, 1, 0, 0.4}
+	// The first ball is the player's, and each ball's own goal is drawn in its colour
+	BallColors = []mgl32.Vec4{
+		PlayerColor,
+		mgl32.Vec4{1, 0.4, 0.2, 1},
+		mgl32.Vec4{0.3, 0.6, 1, 1},
+	}
+	SwitchColor  = mgl32.Vec4{0, 0.6, 0.8, 0.9}
+	PortalColors = []mgl32.Vec4{
 		mgl32.Vec4{0, 0, 0.8, 0.9},
 		mgl32.Vec4{0.27, 0, 0.8, 0.9},
 		mgl32.Vec4{0.54, 0, 0.8, 0.9},
 	}
+	// One way links are drawn as an opaque entrance and a faded exit
+	OneWayColors = []mgl32.Vec4{
+		mgl32.Vec4{1, 0.5, 0, 0.9},
+		mgl32.Vec4{0.9, 0.3, 0, 0.9},
+		mgl32.Vec4{0.8, 0.6, 0, 0.9},
+	}
+	OneWayExitAlpha = float32(0.5)
+	// Each key is drawn in the same colour as the doors it opens
+	LockColors = []mgl32.Vec4{
+		mgl32.Vec4{0.9, 0, 0.5, 0.9},
+		mgl32.Vec4{0.6, 0, 0.2, 0.9},
+		mgl32.Vec4{1, 0.5, 0.7, 0.9},
+	}
 )
@@ -0,0 +1,11 @@
+package perspectivefungo
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// ButtonContains exposes button.contains to the tests, for a button drawn without a model transform.
+func ButtonContains(box *Box, transform mgl32.Mat4, width, height, x, y float32) bool {
+	b := &button{model: mgl32.Ident4()}
+	return b.contains(box, transform, width, height, x, y)
+}
@@ -3,6 +3,7 @@ package perspectivefungo
 import (
 	"fmt"
 	"github.com/go-gl/mathgl/mgl32"
+	"math"
 	"time"
 )
 
@@ -14,7 +15,12 @@ type Game interface {
 	Loop(Driver) error
 	Rotate(float32, float32)
 	RotateToAxis()
+	Turn(int, int)
 	ReleaseBall()
+	Undo()
+	Redo()
+	Hint()
+	HitTest(float32, float32) Button
 	Animating() bool
 	Solution() *Solution
 	GameOver(bool)
@@ -26,6 +32,14 @@ type game struct {
 	puzzle   *Puzzle
 	solution *Solution
 
+	maze  *Maze
+	state *State
+	fall  *Fall
+	hint  *Move // Shown until the ball is next moved
+
+	undos []*checkpoint
+	redos []*checkpoint
+
 	scale    mgl32.Mat4
 	rotation mgl32.Mat4
 
@@ -33,25 +47,41 @@ type game struct {
 	cameraLookAt mgl32.Vec3
 	cameraUp     mgl32.Vec3
 
+	width      float32
+	height     float32
 	projection mgl32.Mat4
 	camera     mgl32.Mat4
 	model      mgl32.Mat4
 	light      mgl32.Vec3
 
+	boxes map[Button]*Box // Enclosing each button mesh
+
 	animation Animation
+	pending   *Event // Awaiting the orientation that results from its animation
 
-	player  [3]float32
-	goal    [3]float32
-	blocks  [][3]float32
-	portals [][3]float32
+	balls     [][3]float32
+	goals     [][3]float32
+	blocks    [][3]float32
+	crumbling [][3]float32
+	crumbles  []float32 // Scale of each crumbling block, zero once it has crumbled
+	portals   [][3]float32
+	oneWays   [][2][3]float32 // Entrance and exit of each one way link
+	switches  []mgl32.Mat4    // Position and direction of each switch
 
 	gameStarted, gameEnded bool
 }
 
+// checkpoint holds what is needed to return to the point before or after a move.
+type checkpoint struct {
+	state    *State
+	rotation mgl32.Mat4
+}
+
 func NewGame(puzzle *Puzzle) Game {
 	fmt.Println("Playing", puzzle)
 	g := &game{
 		puzzle: puzzle,
+		maze:   NewMaze(puzzle),
 	}
 	g.Reset()
 	return g
@@ -61,11 +91,21 @@ func (g *game) Init(d Driver) error {
 	if err := LoadAssets(d); err != nil {
 		return err
 	}
+	g.boxes = make(map[Button]*Box)
+	for id, data := range ButtonMeshes {
+		box, err := OFFBox(data)
+		if err != nil {
+			return err
+		}
+		g.boxes[id] = box
+	}
 	g.Reset()
 	return nil
 }
 
 func (g *game) Resize(width, height float32) {
+	g.width = width
+	g.height = height
 	g.projection = NewProjection(width, height)
 }
 
@@ -84,10 +124,24 @@ func (g *game) Reset() {
 	g.light = NewLight()
 
 	g.animation = nil
-
-	for i := 0; i < 3; i++ {
-		g.player[i] = float32(g.puzzle.Player[i])
-		g.goal[i] = float32(g.puzzle.Goal[i])
+	g.pending = nil
+
+	g.state = g.maze.Start()
+	g.fall = nil
+	g.hint = nil
+	g.undos = nil
+	g.redos = nil
+
+	g.balls = make([][3]float32, len(g.state.Balls))
+	g.showBalls()
+
+	g.goals = nil
+	for i := 0; i+2 < len(g.puzzle.Goal); i += 3 {
+		g.goals = append(g.goals, [3]float32{
+			float32(g.puzzle.Goal[i]),
+			float32(g.puzzle.Goal[i+1]),
+			float32(g.puzzle.Goal[i+2]),
+		})
 	}
 
 	g.blocks = nil
@@ -99,6 +153,17 @@ func (g *game) Reset() {
 		})
 	}
 
+	g.crumbling = nil
+	for i := 0; i < len(g.puzzle.Crumbling); i += 3 {
+		g.crumbling = append(g.crumbling, [3]float32{
+			float32(g.puzzle.Crumbling[i]),
+			float32(g.puzzle.Crumbling[i+1]),
+			float32(g.puzzle.Crumbling[i+2]),
+		})
+	}
+	g.crumbles = make([]float32, len(g.crumbling))
+	g.showCrumbled()
+
 	g.portals = nil
 	for i := 0; i < len(g.puzzle.Portals); i += 3 {
 		g.portals = append(g.portals, [3]float32{
@@ -108,28 +173,82 @@ func (g *game) Reset() {
 		})
 	}
 
+	g.oneWays = nil
+	for _, l := range g.puzzle.Links {
+		from := [3]float32{float32(l.From[0]), float32(l.From[1]), float32(l.From[2])}
+		to := [3]float32{float32(l.To[0]), float32(l.To[1]), float32(l.To[2])}
+		if l.Type == TWO_WAY {
+			// Drawn like the other pairs of portals
+			g.portals = append(g.portals, from, to)
+		} else {
+			g.oneWays = append(g.oneWays, [2][3]float32{from, to})
+		}
+	}
+
+	g.switches = nil
+	for _, s := range g.puzzle.Switches {
+		// The mesh points up, so turn it to point in the direction of the switch
+		direction := mgl32.Vec3{float32(s.Direction[0]), float32(s.Direction[1]), float32(s.Direction[2])}
+		turn := mgl32.QuatBetweenVectors(mgl32.Vec3{0, 1, 0}, direction).Mat4()
+		g.switches = append(g.switches, mgl32.Translate3D(float32(s.Cell[0]), float32(s.Cell[1]), float32(s.Cell[2])).Mul4(turn))
+	}
+
 	g.gameStarted = false
 	g.gameEnded = false
 }
 
 func (g *game) Start() {
 	g.solution = &Solution{
-		Start: time.Now(),
+		Version: SOLUTION_VERSION,
+		Start:   time.Now(),
 	}
 	g.gameStarted = true
 }
 
 func (g *game) Loop(d Driver) error {
-	if a := g.animation; a != nil && a.Tick() {
+	g.update(d.Now())
+	return g.draw(d)
+}
+
+// update advances the current animation to the given time, in seconds, and applies the outcome once it completes.
+func (g *game) update(now float64) {
+	if a := g.animation; a != nil && a.Tick(now) {
 		g.animation = nil
-		if g.solution != nil {
-			g.solution.Progress = append(g.solution.Progress, &Snapshot{
-				Time:     time.Now(),
-				Position: [3]float32{g.player[0], g.player[1], g.player[2]},
-			})
+		if e := g.pending; e != nil {
+			g.pending = nil
+			e.Orientation = NewOrientation(g.rotation)
+		}
+		if f := g.fall; f != nil {
+			g.fall = nil
+			g.state = f.State
+			if g.solution != nil {
+				// Only moving the ball changes its position
+				g.solution.Progress = append(g.solution.Progress, &Snapshot{
+					Time:     time.Now(),
+					Position: g.balls[0],
+				})
+			}
+			var crumbling []*float32
+			for _, b := range f.falls() {
+				if b.Crumbled {
+					crumbling = append(crumbling, &g.crumbles[g.maze.Crumbling[b.Block]])
+				}
+			}
+			if len(crumbling) > 0 {
+				// Blocks give way once the balls have come to rest on them
+				g.animation = NewCrumbleAnimation(crumbling...)
+			}
+			switch f.Outcome {
+			case GOAL:
+				g.GameOver(true)
+			case OUT_OF_BOUNDS, LOOP:
+				g.GameOver(false)
+			}
 		}
 	}
+}
 
+func (g *game) draw(d Driver) error {
 	d.SetProjection(&g.projection)
 	d.SetCamera(&g.camera)
 	d.SetLight(&g.light)
@@ -150,6 +269,19 @@ func (g *game) Rotate(radX, radY float32) {
 		return
 	}
 	// fmt.Println("Rotate:", radX, radY)
+	if s := g.solution; s != nil && len(s.Events) > 0 && s.Events[len(s.Events)-1].Type == ROTATE {
+		// Merge the movements of a drag into a single event, so recordings don't grow with the time spent dragging
+		e := s.Events[len(s.Events)-1]
+		e.Time = time.Now()
+		e.X += radX
+		e.Y += radY
+	} else {
+		g.record(&Event{
+			Type: ROTATE,
+			X:    radX,
+			Y:    radY,
+		})
+	}
 	inverse := g.rotation.Inv()
 
 	if radY != 0 {
@@ -170,15 +302,139 @@ func (g *game) RotateToAxis() {
 		return
 	}
 	// fmt.Println("RotateToAxis")
+	g.pending = &Event{
+		Type: ROTATE_TO_AXIS,
+	}
+	g.record(g.pending)
 	g.animation = NewRotateToAxisAnimation(&g.rotation, g.cameraEye, g.cameraUp)
 }
 
+// Turn animates the maze by quarter turns about the horizontal and vertical axes of the screen, with the same directions as Rotate.
+func (g *game) Turn(x, y int) {
+	if !g.gameStarted || g.gameEnded || g.animation != nil {
+		return
+	}
+	// Start from the nearest axis in case the maze was left part way through a rotation
+	current := Nearest(g.rotation, Gravity(g.rotation)).Mat4()
+	turn := mgl32.HomogRotate3D(float32(x)*math.Pi/2, mgl32.Vec3{1, 0, 0}).Mul4(mgl32.HomogRotate3D(float32(y)*math.Pi/2, mgl32.Vec3{0, 1, 0}))
+	target := NewOrientation(turn.Mul4(current))
+	g.record(&Event{
+		Type:        TURN,
+		X:           float32(x),
+		Y:           float32(y),
+		Orientation: target,
+	})
+	g.animation = NewRotateAnimation(&g.rotation, target.Mat4())
+}
+
 func (g *game) ReleaseBall() {
 	if !g.gameStarted || g.gameEnded {
 		return
 	}
 	// fmt.Println("ReleaseBall")
-	g.animation = NewReleaseBallAnimation(g.puzzle.Size, g.rotation, &g.player, g.goal, g.blocks, g.portals)
+	g.record(&Event{
+		Type:        RELEASE_BALL,
+		Orientation: NewOrientation(g.rotation),
+	})
+	g.undos = append(g.undos, g.checkpoint())
+	g.redos = nil
+	g.hint = nil
+	g.fall = g.maze.Release(g.state, Gravity(g.rotation))
+	g.animation = NewReleaseBallsAnimation(g.balls, g.fall, BallBounce)
+}
+
+// Undo returns the ball and maze to where they were before the last move.
+func (g *game) Undo() {
+	if !g.gameStarted || g.gameEnded || g.animation != nil || len(g.undos) == 0 {
+		return
+	}
+	last := len(g.undos) - 1
+	c := g.undos[last]
+	g.undos = g.undos[:last]
+	g.redos = append(g.redos, g.checkpoint())
+	g.restore(c)
+	g.record(&Event{
+		Type:        UNDO,
+		Orientation: NewOrientation(g.rotation),
+	})
+}
+
+// Redo returns the ball and maze to where they were after the last undone move.
+func (g *game) Redo() {
+	if !g.gameStarted || g.gameEnded || g.animation != nil || len(g.redos) == 0 {
+		return
+	}
+	last := len(g.redos) - 1
+	c := g.redos[last]
+	g.redos = g.redos[:last]
+	g.undos = append(g.undos, g.checkpoint())
+	g.restore(c)
+	g.record(&Event{
+		Type:        REDO,
+		Orientation: NewOrientation(g.rotation),
+	})
+}
+
+// Hint turns the maze to the orientation of the next move on the route with the fewest rotations, and shows where the ball will come to rest.
+func (g *game) Hint() {
+	if !g.gameStarted || g.gameEnded || g.animation != nil {
+		return
+	}
+	move, err := Hint(g.maze, g.state, Gravity(g.rotation))
+	if err != nil {
+		// The goal can no longer be reached, so undoing is the only help
+		return
+	}
+	target := Nearest(g.rotation, move.Direction)
+	g.hint = move
+	g.record(&Event{
+		Type:        HINT,
+		Orientation: target,
+	})
+	g.animation = NewRotateAnimation(&g.rotation, target.Mat4())
+}
+
+func (g *game) checkpoint() *checkpoint {
+	return &checkpoint{
+		state:    g.state,
+		rotation: g.rotation,
+	}
+}
+
+func (g *game) restore(c *checkpoint) {
+	g.hint = nil
+	g.state = c.state
+	g.rotation = c.rotation
+	g.showBalls()
+	g.showCrumbled()
+}
+
+// showBalls moves each ball to its cell in the current state.
+func (g *game) showBalls() {
+	for i, b := range g.state.Balls {
+		for j := 0; j < 3; j++ {
+			g.balls[i][j] = float32(b[j])
+		}
+	}
+}
+
+// showCrumbled sets the scale of each crumbling block to match the current state.
+func (g *game) showCrumbled() {
+	for i := range g.crumbles {
+		if flagged(g.state.Crumbled, i) {
+			g.crumbles[i] = 0
+		} else {
+			g.crumbles[i] = 1
+		}
+	}
+}
+
+func (g *game) record(e *Event) {
+	if g.solution == nil {
+		return
+	}
+	e.Time = time.Now()
+	g.solution.Events = append(g.solution.Events, e)
 }
 
 func (g *game) Animating() bool {
@@ -226,14 +482,6 @@ func (g *game) showEnd(d Driver) error {
 		if err := d.DrawMesh("gameover"); err != nil {
 			return err
 		}
-
-		// Draw retry button
-		d.SetColor(&RetryColor)
-		temp = g.model.Mul4(mgl32.Translate3D(0, -12, 25)).Mul4(mgl32.Scale3D(1, 1, 0.1))
-		d.SetModel(&temp)
-		if err := d.DrawMesh("retry"); err != nil {
-			return err
-		}
 	} else {
 		d.SetColor(&GoalColor)
 		now := float32(d.Now())
@@ -255,25 +503,75 @@ func (g *game) showEnd(d Driver) error {
 			}
 		}
 
-		// Draw retry button
-		d.SetColor(&RetryColor)
-		temp = g.model.Mul4(mgl32.Translate3D(-12, -12, 25)).Mul4(mgl32.Scale3D(1, 1, 0.1))
-		d.SetModel(&temp)
-		if err := d.DrawMesh("retry"); err != nil {
-			return err
+		// Show hints used
+		if hints := g.solution.Hints(); hints > 0 {
+			used := fmt.Sprint(hints)
+			offset := float32(len(used)) / 2
+			d.SetColor(&PlayerColor)
+			temp = g.model.Mul4(mgl32.Translate3D(-offset*3.5-1, -6, 25)).Mul4(mgl32.Scale3D(4, 4, 4))
+			d.SetModel(&temp)
+			if err := d.DrawMesh("player"); err != nil {
+				return err
+			}
+			d.SetColor(&GameWonColor)
+			for i, c := range used {
+				temp = g.model.Mul4(mgl32.Translate3D((float32(i+1)-offset)*3.5, -7, 25))
+				d.SetModel(&temp)
+				if err := d.DrawMesh(string(c)); err != nil {
+					return err
+				}
+			}
 		}
 
-		// Draw share button
-		d.SetColor(&ShareColor)
-		temp = g.model.Mul4(mgl32.Translate3D(12, -12, 25)).Mul4(mgl32.Scale3D(1, 1, 0.1))
-		d.SetModel(&temp)
-		if err := d.DrawMesh("share"); err != nil {
+	}
+
+	for _, b := range g.buttons() {
+		if err := b.draw(d); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// buttons returns the buttons on screen, positioned as they are drawn.
+func (g *game) buttons() []*button {
+	if !g.gameEnded {
+		return nil
+	}
+	if g.solution == nil {
+		return []*button{
+			{
+				id:    BUTTON_RETRY,
+				color: &RetryColor,
+				model: g.model.Mul4(mgl32.Translate3D(0, -12, 25)).Mul4(mgl32.Scale3D(1, 1, 0.1)),
+			},
+		}
+	}
+	return []*button{
+		{
+			id:    BUTTON_RETRY,
+			color: &RetryColor,
+			model: g.model.Mul4(mgl32.Translate3D(-12, -12, 25)).Mul4(mgl32.Scale3D(1, 1, 0.1)),
+		},
+		{
+			id:    BUTTON_SHARE,
+			color: &ShareColor,
+			model: g.model.Mul4(mgl32.Translate3D(12, -12, 25)).Mul4(mgl32.Scale3D(1, 1, 0.1)),
+		},
+	}
+}
+
+// HitTest returns the button drawn at the screen position, measured in pixels from the top left, or BUTTON_NONE if there isn't one.
+func (g *game) HitTest(x, y float32) Button {
+	transform := g.projection.Mul4(g.camera)
+	for _, b := range g.buttons() {
+		if box, ok := g.boxes[b.id]; ok && b.contains(box, transform, g.width, g.height, x, y) {
+			return b.id
+		}
+	}
+	return BUTTON_NONE
+}
+
 func (g *game) showGame(d Driver) error {
 	var temp mgl32.Mat4
 	r := g.model.Mul4(g.scale).Mul4(g.rotation)
@@ -289,6 +587,36 @@ func (g *game) showGame(d Driver) error {
 		}
 	}
 
+	for i, l := range g.oneWays {
+		entrance := OneWayColors[i]
+		d.SetColor(&entrance)
+		temp = r.Mul4(mgl32.Translate3D(l[0][0], l[0][1], l[0][2]))
+		d.SetModel(&temp)
+		if err := d.DrawMesh("portal"); err != nil {
+			return err
+		}
+
+		// The exit is smaller and fainter, so the direction of travel is clear
+		exit := entrance
+		exit[3] = OneWayExitAlpha
+		d.SetColor(&exit)
+		temp = r.Mul4(mgl32.Translate3D(l[1][0], l[1][1], l[1][2])).Mul4(mgl32.Scale3D(0.6, 0.6, 0.6))
+		d.SetModel(&temp)
+		if err := d.DrawMesh("portal"); err != nil {
+			return err
+		}
+	}
+
+	d.SetColor(&SwitchColor)
+	for _, s := range g.switches {
+		temp = r.Mul4(s)
+		d.SetModel(&temp)
+
+		if err := d.DrawMesh("switch"); err != nil {
+			return err
+		}
+	}
+
 	d.SetColor(&BlockColor)
 	for _, b := range g.blocks {
 		temp = r.Mul4(mgl32.Translate3D(b[0], b[1], b[2]))
@@ -299,30 +627,103 @@ func (g *game) showGame(d Driver) error {
 		}
 	}
 
-	d.SetColor(&GoalColor)
-	temp = r.Mul4(mgl32.Translate3D(g.goal[0], g.goal[1], g.goal[2]))
-	d.SetModel(&temp)
+	d.SetColor(&CrumblingColor)
+	for i, b := range g.crumbling {
+		s := g.crumbles[i]
+		if s <= 0 {
+			continue
+		}
+		temp = r.Mul4(mgl32.Translate3D(b[0], b[1], b[2])).Mul4(mgl32.Scale3D(s, s, s))
+		d.SetModel(&temp)
 
-	if err := d.DrawMesh("goal"); err != nil {
-		return err
+		if err := d.DrawMesh("block"); err != nil {
+			return err
+		}
 	}
 
-	d.SetColor(&PlayerColor)
-	temp = r.Mul4(mgl32.Translate3D(g.player[0], g.player[1], g.player[2]))
-	d.SetModel(&temp)
+	for i, l := range g.puzzle.Locks {
+		if flagged(g.state.Keys, i) {
+			// The doors are open once the key has been collected
+			continue
+		}
+		d.SetColor(&LockColors[i])
+		temp = r.Mul4(mgl32.Translate3D(float32(l.Key[0]), float32(l.Key[1]), float32(l.Key[2]))).Mul4(mgl32.Scale3D(0.5, 0.5, 0.5))
+		d.SetModel(&temp)
 
-	if err := d.DrawMesh("player"); err != nil {
-		return err
+		if err := d.DrawMesh("player"); err != nil {
+			return err
+		}
+
+		for _, c := range l.Doors {
+			temp = r.Mul4(mgl32.Translate3D(float32(c[0]), float32(c[1]), float32(c[2])))
+			d.SetModel(&temp)
+
+			if err := d.DrawMesh("block"); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, goal := range g.goals {
+		color := GoalColor
+		if len(g.goals) > 1 {
+			// Each ball has its own goal in a matching colour
+			color = BallColors[i]
+			color[3] = GoalColor[3]
+		}
+		d.SetColor(&color)
+		temp = r.Mul4(mgl32.Translate3D(goal[0], goal[1], goal[2]))
+		d.SetModel(&temp)
+
+		if err := d.DrawMesh("goal"); err != nil {
+			return err
+		}
 	}
-	limit := float32(g.puzzle.Size)
-	if abs(g.player[0]) > limit || abs(g.player[1]) > limit || abs(g.player[2]) > limit {
-		g.GameOver(false)
+
+	for i, b := range g.balls {
+		d.SetColor(&BallColors[i])
+		temp = r.Mul4(mgl32.Translate3D(b[0], b[1], b[2]))
+		d.SetModel(&temp)
+
+		if err := d.DrawMesh("player"); err != nil {
+			return err
+		}
 	}
 
-	if g.goal[0] == g.player[0] && g.goal[1] == g.player[1] && g.goal[2] == g.player[2] {
-		g.GameOver(true)
+	if h := g.hint; h != nil {
+		// Ghost of each ball where the hinted move will leave it
+		cells := h.Cells
+		if cells == nil {
+			cells = [][3]int{h.Cell}
+		}
+		d.SetColor(&HintColor)
+		for _, c := range cells {
+			temp = r.Mul4(mgl32.Translate3D(float32(c[0]), float32(c[1]), float32(c[2])))
+			d.SetModel(&temp)
+
+			if err := d.DrawMesh("player"); err != nil {
+				return err
+			}
+		}
 	}
 
+	// Show keys collected in a row above the maze, which doesn't rotate
+	var held []int
+	for i := range g.puzzle.Locks {
+		if flagged(g.state.Keys, i) {
+			held = append(held, i)
+		}
+	}
+	offset := float32(len(held)-1) / 2
+	for j, i := range held {
+		d.SetColor(&LockColors[i])
+		temp = g.model.Mul4(mgl32.Translate3D((float32(j)-offset)*0.3, 1.6, 0)).Mul4(mgl32.Scale3D(0.2, 0.2, 0.2))
+		d.SetModel(&temp)
+
+		if err := d.DrawMesh("player"); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,257 @@
+package perspectivefungo_test
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestGameUndo(t *testing.T) {
+	p := &perspectivefungo.Puzzle{
+		Size:   5,
+		Player: []int{0, 0, 0},
+		Goal:   []int{0, -2, 1},
+		Blocks: []int{0, -2, 0, 0, 0, 2},
+	}
+	d := &clockDriver{}
+	last := func(g perspectivefungo.Game) *perspectivefungo.Event {
+		events := g.Solution().Events
+		return events[len(events)-1]
+	}
+	g := perspectivefungo.NewGame(p)
+	assert.Nil(t, d.Init(g))
+	g.Start()
+
+	// Nothing to undo or redo
+	g.Undo()
+	g.Redo()
+	assert.Empty(t, g.Solution().Events)
+
+	// Fall onto the block below
+	g.ReleaseBall()
+	runSecond(t, d, g)
+	g.Undo()
+	assert.Equal(t, perspectivefungo.UNDO, last(g).Type)
+	g.Redo()
+	assert.Equal(t, perspectivefungo.REDO, last(g).Type)
+	g.Undo()
+
+	// Fall onto the block behind, then down into the goal
+	g.Rotate(math.Pi/2, 0)
+	g.RotateToAxis()
+	runSecond(t, d, g)
+	g.ReleaseBall()
+	runSecond(t, d, g)
+	g.Rotate(-math.Pi/2, 0)
+	g.RotateToAxis()
+	runSecond(t, d, g)
+	g.ReleaseBall()
+	runSecond(t, d, g)
+	assert.True(t, g.HasGameEnded())
+
+	s := g.Solution()
+	if !assert.NotNil(t, s) {
+		return
+	}
+	assert.Equal(t, uint(2), s.Undos())
+	r, err := s.Replay(p)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), r.Rotations)
+	assert.Equal(t, []*perspectivefungo.Move{
+		{Direction: [3]int{0, 0, 1}, Cell: [3]int{0, 0, 1}},
+		{Direction: [3]int{0, -1, 0}, Cell: [3]int{0, -2, 1}},
+	}, r.Moves)
+}
+
+func TestGameCrumbling(t *testing.T) {
+	p := &perspectivefungo.Puzzle{
+		Size:      5,
+		Player:    []int{0, 2, 0},
+		Goal:      []int{0, -2, 0},
+		Crumbling: []int{0, 0, 0},
+	}
+	d := &clockDriver{}
+	g := perspectivefungo.NewGame(p)
+	assert.Nil(t, d.Init(g))
+	g.Start()
+
+	// Fall onto the block, which crumbles
+	g.ReleaseBall()
+	runSecond(t, d, g)
+	assert.False(t, g.Animating())
+	assert.False(t, g.HasGameEnded())
+
+	// Undoing restores the block, so the ball is stopped again
+	g.Undo()
+	g.ReleaseBall()
+	runSecond(t, d, g)
+	assert.False(t, g.HasGameEnded())
+
+	// Fall through where the block was into the goal
+	g.ReleaseBall()
+	runSecond(t, d, g)
+	assert.True(t, g.HasGameEnded())
+
+	s := g.Solution()
+	if !assert.NotNil(t, s) {
+		return
+	}
+	assert.Equal(t, uint(1), s.Undos())
+	r, err := s.Replay(p)
+	assert.Nil(t, err)
+	assert.Equal(t, []*perspectivefungo.Move{
+		{Direction: [3]int{0, -1, 0}, Cell: [3]int{0, 1, 0}},
+		{Direction: [3]int{0, -1, 0}, Cell: [3]int{0, -2, 0}},
+	}, r.Moves)
+}
+
+func TestGameBalls(t *testing.T) {
+	p := &perspectivefungo.Puzzle{
+		Size:   5,
+		Player: []int{0, 2, 0, 1, 2, 0},
+		Goal:   []int{0, -2, 0, 1, -2, 1},
+		Blocks: []int{1, -1, 0, 1, 0, 2},
+	}
+	d := &clockDriver{}
+	g := perspectivefungo.NewGame(p)
+	assert.Nil(t, d.Init(g))
+	g.Start()
+
+	// The first ball reaches its goal, but the game continues until the second does
+	g.ReleaseBall()
+	runSecond(t, d, g)
+	assert.False(t, g.HasGameEnded())
+
+	// Turn so the second ball rolls off the block, then back so it falls into its goal
+	g.Turn(1, 0)
+	runSecond(t, d, g)
+	g.ReleaseBall()
+	runSecond(t, d, g)
+	assert.False(t, g.HasGameEnded())
+	g.Turn(-1, 0)
+	runSecond(t, d, g)
+	g.ReleaseBall()
+	runSecond(t, d, g)
+	assert.True(t, g.HasGameEnded())
+
+	s := g.Solution()
+	if !assert.NotNil(t, s) {
+		return
+	}
+	r, err := s.Replay(p)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), r.Rotations)
+	assert.Len(t, r.Moves, 3)
+}
+
+func TestGameHint(t *testing.T) {
+	p := &perspectivefungo.Puzzle{
+		Size:   5,
+		Player: []int{0, 0, 0},
+		Goal:   []int{0, -2, 1},
+		Blocks: []int{0, -2, 0, 0, 0, 2},
+	}
+	d := &clockDriver{}
+	g := perspectivefungo.NewGame(p)
+	assert.Nil(t, d.Init(g))
+
+	// Hints are only given once the game has started
+	g.Hint()
+	assert.False(t, g.Animating())
+	g.Start()
+
+	// Turn so the ball falls onto the block behind
+	g.Hint()
+	assert.True(t, g.Animating())
+	runSecond(t, d, g)
+	assert.False(t, g.Animating())
+	g.ReleaseBall()
+	runSecond(t, d, g)
+
+	// Turn back so the ball falls into the goal
+	g.Hint()
+	runSecond(t, d, g)
+	g.ReleaseBall()
+	runSecond(t, d, g)
+	assert.True(t, g.HasGameEnded())
+
+	s := g.Solution()
+	if !assert.NotNil(t, s) {
+		return
+	}
+	assert.Equal(t, uint(2), s.Hints())
+	assert.Equal(t, perspectivefungo.HINT, s.Events[0].Type)
+	assert.Equal(t, [3]int{0, 0, 1}, s.Events[0].Orientation.Gravity())
+	// Only moving the ball is snapshot, not turning to the hint
+	if assert.Len(t, s.Progress, 2) {
+		assert.Equal(t, [3]float32{0, 0, 1}, s.Progress[0].Position)
+		assert.Equal(t, [3]float32{0, -2, 1}, s.Progress[1].Position)
+	}
+	r, err := s.Replay(p)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), r.Rotations)
+	assert.Equal(t, []*perspectivefungo.Move{
+		{Direction: [3]int{0, 0, 1}, Cell: [3]int{0, 0, 1}},
+		{Direction: [3]int{0, -1, 0}, Cell: [3]int{0, -2, 1}},
+	}, r.Moves)
+}
+
+func TestGameHitTest(t *testing.T) {
+	d := &clockDriver{}
+	// end plays the puzzle until the ball stops, and returns the game
+	end := func(t *testing.T, p *perspectivefungo.Puzzle) perspectivefungo.Game {
+		t.Helper()
+		g := perspectivefungo.NewGame(p)
+		assert.Nil(t, d.Init(g))
+		g.Resize(400, 300)
+		g.Start()
+		assert.Equal(t, perspectivefungo.BUTTON_NONE, g.HitTest(200, 250))
+		g.ReleaseBall()
+		runSecond(t, d, g)
+		assert.True(t, g.HasGameEnded())
+		return g
+	}
+	t.Run("Won", func(t *testing.T) {
+		g := end(t, &perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 1, 0},
+			Goal:   []int{0, -1, 0},
+		})
+		rx, ry, ok := find(g, 400, 300, perspectivefungo.BUTTON_RETRY)
+		assert.True(t, ok)
+		sx, sy, ok := find(g, 400, 300, perspectivefungo.BUTTON_SHARE)
+		assert.True(t, ok)
+		// Buttons are side by side below the middle of the screen
+		assert.Less(t, rx, 200.)
+		assert.Greater(t, sx, 200.)
+		assert.Greater(t, ry, 150.)
+		assert.InDelta(t, ry, sy, 10)
+		assert.Equal(t, perspectivefungo.BUTTON_NONE, g.HitTest(200, 150))
+	})
+	t.Run("Lost", func(t *testing.T) {
+		g := end(t, &perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 1, 0},
+			Goal:   []int{1, -1, 0},
+		})
+		x, y, ok := find(g, 400, 300, perspectivefungo.BUTTON_RETRY)
+		assert.True(t, ok)
+		// Retry is centered below the middle of the screen
+		assert.Less(t, x, 200.)
+		assert.Greater(t, y, 150.)
+		assert.Equal(t, perspectivefungo.BUTTON_RETRY, g.HitTest(200, float32(y)))
+		_, _, ok = find(g, 400, 300, perspectivefungo.BUTTON_SHARE)
+		assert.False(t, ok)
+	})
+	t.Run("Resized", func(t *testing.T) {
+		g := end(t, &perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 1, 0},
+			Goal:   []int{0, -1, 0},
+		})
+		x, y, _ := find(g, 400, 300, perspectivefungo.BUTTON_SHARE)
+		g.Resize(800, 600)
+		assert.Equal(t, perspectivefungo.BUTTON_SHARE, g.HitTest(float32(x*2), float32(y*2)))
+	})
+}
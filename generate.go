@@ -1,48 +1,85 @@
 package perspectivefungo
 
 import (
+	"errors"
 	"math/rand"
-	"time"
 )
 
-func Generate(size, blocks, portals uint) (*Puzzle, error) {
-	rand.Seed(time.Now().UnixNano())
+var ErrTooManyElements = errors.New("Too many elements for puzzle size")
+
+// Generate creates a random puzzle, the same seed and parameters always generate the same puzzle.
+func Generate(seed int64, size, blocks, portals uint) (*Puzzle, error) {
+	if 2+blocks+portals > size*size*size {
+		return nil, ErrTooManyElements
+	}
+
+	rng := rand.New(rand.NewSource(seed))
 
 	occupied := make(map[string]bool, 2+blocks+portals)
 
 	p := &Puzzle{
 		Size: size,
+		Seed: &seed,
+	}
+	var err error
+	// location returns the next free cell, or nil once an error has occurred
+	location := func() []int {
+		if err != nil {
+			return nil
+		}
+		var l []int
+		l, err = GenerateLocation(rng, occupied, size)
+		return l
 	}
-	p.Player = GenerateLocation(occupied, size)
-	p.Goal = GenerateLocation(occupied, size)
+	p.Player = location()
+	p.Goal = location()
 	for i := uint(0); i < blocks; i++ {
-		p.Blocks = append(p.Blocks, GenerateLocation(occupied, size)...)
+		p.Blocks = append(p.Blocks, location()...)
 	}
 	for i := uint(0); i < portals/2; i++ {
-		p.Portals = append(p.Portals, GenerateLocation(occupied, size)...)
-		p.Portals = append(p.Portals, GenerateLocation(occupied, size)...)
+		p.Portals = append(p.Portals, location()...)
+		p.Portals = append(p.Portals, location()...)
+	}
+	if err != nil {
+		return nil, err
 	}
 	return p, nil
 }
 
-func GenerateLocation(occupied map[string]bool, size uint) []int {
-	var (
-		x, y, z int
-		key     string
-	)
-	for {
-		x = RandomLocation(size)
-		y = RandomLocation(size)
-		z = RandomLocation(size)
-		key = Key(x, y, z)
-		if !occupied[key] {
+// GenerateLocation returns a random cell which isn't occupied and marks it as occupied, or ErrTooManyElements if every cell is occupied.
+func GenerateLocation(rng *rand.Rand, occupied map[string]bool, size uint) ([]int, error) {
+	cells := int(size * size * size)
+	// Guessing is quickest while most cells are free
+	for i := 0; i < cells; i++ {
+		x := RandomLocation(rng, size)
+		y := RandomLocation(rng, size)
+		z := RandomLocation(rng, size)
+		if key := Key(x, y, z); !occupied[key] {
 			occupied[key] = true
-			return []int{x, y, z}
+			return []int{x, y, z}, nil
+		}
+	}
+	// Otherwise choose from the cells which remain
+	min, max := Bounds(size)
+	var free [][]int
+	for x := min; x <= max; x++ {
+		for y := min; y <= max; y++ {
+			for z := min; z <= max; z++ {
+				if !occupied[Key(x, y, z)] {
+					free = append(free, []int{x, y, z})
+				}
+			}
 		}
 	}
+	if len(free) == 0 {
+		return nil, ErrTooManyElements
+	}
+	c := free[rng.Intn(len(free))]
+	occupied[Key(c[0], c[1], c[2])] = true
+	return c, nil
 }
 
-func RandomLocation(size uint) int {
+func RandomLocation(rng *rand.Rand, size uint) int {
 	s := int(size)
-	return rand.Intn(s) - s/2
+	return rng.Intn(s) - s/2
 }
@@ -0,0 +1,64 @@
+package perspectivefungo_test
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Run("Reproducible", func(t *testing.T) {
+		a, err := perspectivefungo.Generate(1234, 7, 8, 4)
+		assert.Nil(t, err)
+		b, err := perspectivefungo.Generate(1234, 7, 8, 4)
+		assert.Nil(t, err)
+		assert.Equal(t, a, b)
+		assert.Equal(t, int64(1234), *a.Seed)
+		assert.Nil(t, a.Validate())
+	})
+	t.Run("Seeded", func(t *testing.T) {
+		a, err := perspectivefungo.Generate(1234, 7, 8, 4)
+		assert.Nil(t, err)
+		b, err := perspectivefungo.Generate(4321, 7, 8, 4)
+		assert.Nil(t, err)
+		assert.NotEqual(t, a, b)
+	})
+	t.Run("Stable", func(t *testing.T) {
+		// Changes to generation would prevent previously logged seeds from being regenerated
+		p, err := perspectivefungo.Generate(1, 5, 2, 2)
+		assert.Nil(t, err)
+		seed := int64(1)
+		assert.Equal(t, &perspectivefungo.Puzzle{
+			Size:    5,
+			Player:  []int{-1, 0, 0},
+			Goal:    []int{2, -1, 1},
+			Blocks:  []int{-2, -2, -1, -2, 2, -1},
+			Portals: []int{0, 2, 1, 2, -1, -2},
+			Seed:    &seed,
+		}, p)
+	})
+	t.Run("TooManyElements", func(t *testing.T) {
+		_, err := perspectivefungo.Generate(1234, 2, 6, 2)
+		assert.Equal(t, perspectivefungo.ErrTooManyElements, err)
+	})
+}
+
+func TestGenerateLocation(t *testing.T) {
+	rng := rand.New(rand.NewSource(1234))
+	occupied := make(map[string]bool)
+	for x := -1; x <= 0; x++ {
+		for y := -1; y <= 0; y++ {
+			for z := -1; z <= 0; z++ {
+				occupied[perspectivefungo.Key(x, y, z)] = true
+			}
+		}
+	}
+	delete(occupied, perspectivefungo.Key(0, -1, 0))
+	// The last free cell is found however unlikely it is to be guessed
+	l, err := perspectivefungo.GenerateLocation(rng, occupied, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, -1, 0}, l)
+	_, err = perspectivefungo.GenerateLocation(rng, occupied, 2)
+	assert.Equal(t, perspectivefungo.ErrTooManyElements, err)
+}
@@ -0,0 +1,147 @@
+package perspectivefungo
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrNoPuzzleFound = errors.New("No puzzle found")
+
+type GeneratorOptions struct {
+	Size      uint
+	Blocks    uint
+	Portals   uint
+	Rotations uint          // Stop once a puzzle requires at least this many rotations
+	Penalties uint          // Ignore puzzles with more than this many penalties
+	Seed      int64         // Each try uses the next seed
+	Tries     uint64        // Stop after this many tries, zero for no limit
+	Workers   int           // Zero uses every CPU
+	Interval  time.Duration // How often progress is reported
+}
+
+type GeneratorProgress struct {
+	Tries     uint64
+	Elapsed   time.Duration
+	Best      *Puzzle
+	Rotations uint
+	Penalties uint
+}
+
+// Rate returns the number of tries per second.
+func (p *GeneratorProgress) Rate() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Tries) / p.Elapsed.Seconds()
+}
+
+// GenerateBest generates and scores puzzles in parallel until one reaches the target rotations, the tries are exhausted, or the context is done, and returns the best puzzle found.
+// When two puzzles score the same the one with the lowest seed wins, so the result only depends on the order workers finish if the search stops early.
+func GenerateBest(ctx context.Context, options *GeneratorOptions, progress func(*GeneratorProgress)) (*Puzzle, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	var (
+		next      uint64
+		tries     uint64
+		mutex     sync.Mutex
+		best      *Puzzle
+		rotations uint
+		penalties uint
+		err       error
+		wg        sync.WaitGroup
+		start     = time.Now()
+	)
+
+	report := func() {
+		if progress == nil {
+			return
+		}
+		mutex.Lock()
+		p := &GeneratorProgress{
+			Tries:     atomic.LoadUint64(&tries),
+			Elapsed:   time.Since(start),
+			Best:      best,
+			Rotations: rotations,
+			Penalties: penalties,
+		}
+		mutex.Unlock()
+		progress(p)
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				i := atomic.AddUint64(&next, 1) - 1
+				if options.Tries > 0 && i >= options.Tries {
+					return
+				}
+				p, e := Generate(options.Seed+int64(i), options.Size, options.Blocks, options.Portals)
+				if e != nil {
+					mutex.Lock()
+					err = e
+					mutex.Unlock()
+					cancel()
+					return
+				}
+				rs, ps, solved := Score(p)
+				atomic.AddUint64(&tries, 1)
+				if !solved || ps > options.Penalties {
+					continue
+				}
+				mutex.Lock()
+				if best == nil || rs > rotations || (rs == rotations && (ps < penalties || (ps == penalties && *p.Seed < *best.Seed))) {
+					best = p
+					rotations = rs
+					penalties = ps
+					if rs >= options.Rotations {
+						cancel()
+					}
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	if options.Interval > 0 {
+		ticker := time.NewTicker(options.Interval)
+		defer ticker.Stop()
+	loop:
+		for {
+			select {
+			case <-done:
+				break loop
+			case <-ticker.C:
+				report()
+			}
+		}
+	} else {
+		<-done
+	}
+	report()
+
+	if err != nil {
+		return nil, err
+	}
+	if best == nil {
+		return nil, ErrNoPuzzleFound
+	}
+	return best, nil
+}
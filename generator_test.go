@@ -0,0 +1,105 @@
+package perspectivefungo_test
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGenerateBest(t *testing.T) {
+	t.Run("Workers", func(t *testing.T) {
+		options := &perspectivefungo.GeneratorOptions{
+			Size:      5,
+			Blocks:    4,
+			Portals:   2,
+			Rotations: math.MaxUint32,
+			Penalties: math.MaxUint32,
+			Seed:      1234,
+			Tries:     500,
+		}
+		options.Workers = 1
+		a, err := perspectivefungo.GenerateBest(context.Background(), options, nil)
+		assert.Nil(t, err)
+		options.Workers = 4
+		var progress *perspectivefungo.GeneratorProgress
+		b, err := perspectivefungo.GenerateBest(context.Background(), options, func(p *perspectivefungo.GeneratorProgress) {
+			progress = p
+		})
+		assert.Nil(t, err)
+		// Result doesn't depend on the number of workers
+		assert.Equal(t, a, b)
+		assert.Equal(t, uint64(500), progress.Tries)
+		assert.Equal(t, b, progress.Best)
+		rotations, penalties, _ := perspectivefungo.Score(b)
+		assert.Equal(t, rotations, progress.Rotations)
+		assert.Equal(t, penalties, progress.Penalties)
+	})
+	t.Run("Target", func(t *testing.T) {
+		p, err := perspectivefungo.GenerateBest(context.Background(), &perspectivefungo.GeneratorOptions{
+			Size:      5,
+			Blocks:    4,
+			Portals:   2,
+			Rotations: 2,
+			Penalties: math.MaxUint32,
+			Seed:      1234,
+		}, nil)
+		assert.Nil(t, err)
+		rotations, _, _ := perspectivefungo.Score(p)
+		assert.GreaterOrEqual(t, rotations, uint(2))
+	})
+	t.Run("Timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		// Target cannot be reached, so best puzzle is returned when context is done
+		p, err := perspectivefungo.GenerateBest(ctx, &perspectivefungo.GeneratorOptions{
+			Size:      5,
+			Blocks:    4,
+			Portals:   2,
+			Rotations: math.MaxUint32,
+			Penalties: math.MaxUint32,
+			Seed:      1234,
+		}, nil)
+		assert.Nil(t, err)
+		assert.NotNil(t, p)
+	})
+	t.Run("Direct", func(t *testing.T) {
+		// Seed 21 is solved without rotating, which is still a puzzle
+		p, err := perspectivefungo.GenerateBest(context.Background(), &perspectivefungo.GeneratorOptions{
+			Size:      5,
+			Blocks:    4,
+			Portals:   2,
+			Rotations: math.MaxUint32,
+			Penalties: math.MaxUint32,
+			Seed:      21,
+			Tries:     1,
+		}, nil)
+		assert.Nil(t, err)
+		if assert.NotNil(t, p) {
+			assert.Equal(t, int64(21), *p.Seed)
+		}
+	})
+	t.Run("Unsolvable", func(t *testing.T) {
+		// Seed 0 cannot be solved, so is skipped
+		_, err := perspectivefungo.GenerateBest(context.Background(), &perspectivefungo.GeneratorOptions{
+			Size:      5,
+			Blocks:    4,
+			Portals:   2,
+			Rotations: math.MaxUint32,
+			Penalties: math.MaxUint32,
+			Seed:      0,
+			Tries:     1,
+		}, nil)
+		assert.Equal(t, perspectivefungo.ErrNoPuzzleFound, err)
+	})
+	t.Run("Error", func(t *testing.T) {
+		_, err := perspectivefungo.GenerateBest(context.Background(), &perspectivefungo.GeneratorOptions{
+			Size:    2,
+			Blocks:  8,
+			Portals: 2,
+		}, nil)
+		assert.Equal(t, perspectivefungo.ErrTooManyElements, err)
+	})
+}
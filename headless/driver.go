@@ -0,0 +1,278 @@
+package headless
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Driver renders on the CPU into an image, so games can be drawn without a GPU or window.
+type Driver struct {
+	game perspectivefungo.Game
+
+	width, height int
+
+	projection mgl32.Mat4
+	camera     mgl32.Mat4
+	model      mgl32.Mat4
+	light      mgl32.Vec3
+	color      mgl32.Vec4
+
+	meshes map[string]*Mesh
+
+	colors []mgl32.Vec4
+	depths []float32
+
+	previousTime float64
+}
+
+type Mesh struct {
+	Count    int32
+	Vertices []float32
+	Normals  []float32
+}
+
+func NewDriver(width, height int) *Driver {
+	d := &Driver{
+		width:      width,
+		height:     height,
+		projection: mgl32.Ident4(),
+		camera:     mgl32.Ident4(),
+		model:      mgl32.Ident4(),
+		meshes:     make(map[string]*Mesh),
+		colors:     make([]mgl32.Vec4, width*height),
+		depths:     make([]float32, width*height),
+	}
+	d.Clear()
+	return d
+}
+
+func (d *Driver) Init(game perspectivefungo.Game) error {
+	d.game = game
+	if err := d.game.Init(d); err != nil {
+		return err
+	}
+	d.game.Resize(float32(d.width), float32(d.height))
+	return nil
+}
+
+func (d *Driver) Loop(now float64) error {
+	d.previousTime = now
+	d.Clear()
+	return d.game.Loop(d)
+}
+
+func (d *Driver) Now() float64 {
+	return d.previousTime
+}
+
+// Clear fills the image with the background color and resets the depth buffer.
+func (d *Driver) Clear() {
+	for i := range d.colors {
+		d.colors[i] = perspectivefungo.BackgroundColor
+		d.depths[i] = 1
+	}
+}
+
+func (d *Driver) AddMesh(id string, count int32, vertices, normals []float32) error {
+	if len(vertices) < int(count)*3 || len(normals) < int(count)*3 {
+		return fmt.Errorf("Mesh %s has fewer than %d vertices", id, count)
+	}
+	d.meshes[id] = &Mesh{
+		Count:    count,
+		Vertices: vertices,
+		Normals:  normals,
+	}
+	return nil
+}
+
+func (d *Driver) SetProjection(p *mgl32.Mat4) {
+	d.projection = *p
+}
+
+func (d *Driver) SetCamera(c *mgl32.Mat4) {
+	d.camera = *c
+}
+
+func (d *Driver) SetModel(m *mgl32.Mat4) {
+	d.model = *m
+}
+
+func (d *Driver) SetLight(l *mgl32.Vec3) {
+	d.light = *l
+}
+
+func (d *Driver) SetColor(c *mgl32.Vec4) {
+	d.color = *c
+}
+
+// Image returns a copy of the last rendered frame.
+func (d *Driver) Image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, d.width, d.height))
+	for y := 0; y < d.height; y++ {
+		for x := 0; x < d.width; x++ {
+			c := d.colors[y*d.width+x]
+			// Like a window, the image is opaque whatever alpha was blended into it
+			img.SetRGBA(x, y, color.RGBA{
+				R: channel(c[0]),
+				G: channel(c[1]),
+				B: channel(c[2]),
+				A: 255,
+			})
+		}
+	}
+	return img
+}
+
+// vertex holds the output of the vertex shader.
+type vertex struct {
+	position mgl32.Vec3 // Camera space, the shader's v_Position
+	normal   mgl32.Vec3 // Camera space, the shader's v_Normal
+	screen   mgl32.Vec3 // Window coordinates and depth
+	w        float32    // Clip space w, used for perspective correct interpolation
+}
+
+func (d *Driver) DrawMesh(id string) error {
+	m, ok := d.meshes[id]
+	if !ok {
+		return fmt.Errorf("Unrecognized mesh: %s", id)
+	}
+	cm := d.camera.Mul4(d.model)
+	pcm := d.projection.Mul4(cm)
+	var triangle [3]*vertex
+	for i := 0; i < int(m.Count); i++ {
+		p := mgl32.Vec4{m.Vertices[i*3], m.Vertices[i*3+1], m.Vertices[i*3+2], 1}
+		n := mgl32.Vec4{m.Normals[i*3], m.Normals[i*3+1], m.Normals[i*3+2], 0}
+		triangle[i%3] = d.shadeVertex(cm, pcm, p, n)
+		if i%3 == 2 {
+			d.rasterise(triangle)
+		}
+	}
+	return nil
+}
+
+// shadeVertex mirrors Shader.vp, and the viewport transform.
+func (d *Driver) shadeVertex(cm, pcm mgl32.Mat4, p, n mgl32.Vec4) *vertex {
+	normal := cm.Mul4x1(n).Vec3()
+	if l := normal.Len(); l > 0 {
+		normal = normal.Mul(1 / l)
+	}
+	clip := pcm.Mul4x1(p)
+	v := &vertex{
+		position: cm.Mul4x1(p).Vec3(),
+		normal:   normal,
+		w:        clip[3],
+	}
+	if clip[3] <= 0 || clip[2] < -clip[3] || clip[2] > clip[3] {
+		// Outside of the near or far planes
+		return nil
+	}
+	ndc := clip.Vec3().Mul(1 / clip[3])
+	v.screen = mgl32.Vec3{
+		(ndc[0] + 1) / 2 * float32(d.width),
+		(1 - ndc[1]) / 2 * float32(d.height),
+		(ndc[2] + 1) / 2,
+	}
+	return v
+}
+
+// rasterise fills the pixels whose centres are covered by the triangle, keeping those nearest the camera.
+func (d *Driver) rasterise(t [3]*vertex) {
+	if t[0] == nil || t[1] == nil || t[2] == nil {
+		// Triangles crossing the near or far planes are discarded rather than clipped
+		return
+	}
+	a, b, c := t[0].screen, t[1].screen, t[2].screen
+	area := edge(a, b, c)
+	if area == 0 {
+		return
+	}
+	minX := clamp(int(math.Floor(float64(min3(a[0], b[0], c[0])))), 0, d.width-1)
+	maxX := clamp(int(math.Ceil(float64(max3(a[0], b[0], c[0])))), 0, d.width-1)
+	minY := clamp(int(math.Floor(float64(min3(a[1], b[1], c[1])))), 0, d.height-1)
+	maxY := clamp(int(math.Ceil(float64(max3(a[1], b[1], c[1])))), 0, d.height-1)
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			p := mgl32.Vec3{float32(x) + 0.5, float32(y) + 0.5, 0}
+			// Barycentric weights, normalised so both windings are drawn
+			w0 := edge(b, c, p) / area
+			w1 := edge(c, a, p) / area
+			w2 := edge(a, b, p) / area
+			if w0 < 0 || w1 < 0 || w2 < 0 {
+				continue
+			}
+			i := y*d.width + x
+			z := w0*a[2] + w1*b[2] + w2*c[2]
+			if z > d.depths[i] {
+				continue
+			}
+			d.depths[i] = z
+			// Perspective correct interpolation of the varyings
+			p0 := w0 / t[0].w
+			p1 := w1 / t[1].w
+			p2 := w2 / t[2].w
+			sum := p0 + p1 + p2
+			p0, p1, p2 = p0/sum, p1/sum, p2/sum
+			position := t[0].position.Mul(p0).Add(t[1].position.Mul(p1)).Add(t[2].position.Mul(p2))
+			normal := t[0].normal.Mul(p0).Add(t[1].normal.Mul(p1)).Add(t[2].normal.Mul(p2))
+			d.blend(i, d.shadeFragment(position, normal))
+		}
+	}
+}
+
+// shadeFragment mirrors Shader.fp.
+func (d *Driver) shadeFragment(position, normal mgl32.Vec3) mgl32.Vec4 {
+	diff := d.light.Sub(position)
+	if l := diff.Len(); l > 0 {
+		diff = diff.Mul(1 / l)
+	}
+	diffuse := (normal.Dot(diff) + 1) / 2
+	return mgl32.Vec4{
+		d.color[0] * diffuse,
+		d.color[1] * diffuse,
+		d.color[2] * diffuse,
+		d.color[3],
+	}
+}
+
+// blend mirrors glBlendFunc(GL_SRC_ALPHA, GL_ONE_MINUS_SRC_ALPHA).
+func (d *Driver) blend(i int, src mgl32.Vec4) {
+	dst := d.colors[i]
+	a := src[3]
+	d.colors[i] = src.Mul(a).Add(dst.Mul(1 - a))
+}
+
+func edge(a, b, c mgl32.Vec3) float32 {
+	return (c[0]-a[0])*(b[1]-a[1]) - (c[1]-a[1])*(b[0]-a[0])
+}
+
+func channel(f float32) uint8 {
+	if f <= 0 {
+		return 0
+	}
+	if f >= 1 {
+		return 255
+	}
+	return uint8(f*255 + 0.5)
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+func min3(a, b, c float32) float32 {
+	return float32(math.Min(float64(a), math.Min(float64(b), float64(c))))
+}
+
+func max3(a, b, c float32) float32 {
+	return float32(math.Max(float64(a), math.Max(float64(b), float64(c))))
+}
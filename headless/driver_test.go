@@ -0,0 +1,138 @@
+package headless_test
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"aletheiaware.com/perspectivefungo/headless"
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	WIDTH     = 160
+	HEIGHT    = 120
+	TOLERANCE = 8 // Maximum difference of a color channel
+)
+
+var update = flag.Bool("update", false, "Update golden images")
+
+var puzzle = &perspectivefungo.Puzzle{
+	Size:    5,
+	Player:  []int{0, 1, 0},
+	Goal:    []int{-2, -1, 0},
+	Blocks:  []int{0, -2, 0, 1, 1, -1},
+	Portals: []int{2, 2, 2, -2, 2, -2},
+}
+
+func TestDriver(t *testing.T) {
+	t.Run("Start", func(t *testing.T) {
+		d := headless.NewDriver(WIDTH, HEIGHT)
+		g := perspectivefungo.NewGame(puzzle)
+		assert.Nil(t, d.Init(g))
+		assert.Nil(t, d.Loop(0))
+		assertGolden(t, "start.png", d.Image())
+	})
+	t.Run("Game", func(t *testing.T) {
+		d := headless.NewDriver(WIDTH, HEIGHT)
+		g := perspectivefungo.NewGame(puzzle)
+		assert.Nil(t, d.Init(g))
+		g.Start()
+		g.Rotate(0.3, 0.6)
+		assert.Nil(t, d.Loop(0))
+		assertGolden(t, "game.png", d.Image())
+	})
+	t.Run("Lost", func(t *testing.T) {
+		d := headless.NewDriver(WIDTH, HEIGHT)
+		g := perspectivefungo.NewGame(puzzle)
+		assert.Nil(t, d.Init(g))
+		g.Start()
+		g.Rotate(3.14159, 0)
+		g.RotateToAxis()
+		run(t, d, g, 0, 2)
+		g.ReleaseBall()
+		run(t, d, g, 2, 4)
+		assert.True(t, g.HasGameEnded())
+		assert.Nil(t, g.Solution())
+		assertGolden(t, "lost.png", d.Image())
+	})
+	t.Run("Won", func(t *testing.T) {
+		// Replay a recording so the time shown is always the same
+		start := time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)
+		r, err := perspectivefungo.NewReplay(puzzle, &perspectivefungo.Solution{
+			Version: perspectivefungo.SOLUTION_VERSION,
+			Start:   start,
+			End:     start.Add(2500 * time.Millisecond),
+			Events: []*perspectivefungo.Event{
+				{Time: start.Add(time.Second), Type: perspectivefungo.RELEASE_BALL, Orientation: &perspectivefungo.Orientation{1, 0, 0, 0, 1, 0, 0, 0, 1}},
+				{Time: start.Add(2 * time.Second), Type: perspectivefungo.RELEASE_BALL, Orientation: &perspectivefungo.Orientation{0, -1, 0, 1, 0, 0, 0, 0, 1}},
+			},
+		})
+		assert.Nil(t, err)
+		d := headless.NewDriver(WIDTH, HEIGHT)
+		assert.Nil(t, d.Init(r))
+		run(t, d, r, 0, 5)
+		assert.True(t, r.HasGameEnded())
+		assert.NotNil(t, r.Solution())
+		assertGolden(t, "won.png", d.Image())
+	})
+	t.Run("UnrecognizedMesh", func(t *testing.T) {
+		d := headless.NewDriver(WIDTH, HEIGHT)
+		assert.NotNil(t, d.DrawMesh("missing"))
+	})
+}
+
+// run loops the driver at 60 frames per second between the given times.
+func run(t *testing.T, d *headless.Driver, g perspectivefungo.Game, from, to float64) {
+	t.Helper()
+	for now := from; now < to; now += 1. / 60 {
+		assert.Nil(t, d.Loop(now))
+	}
+}
+
+func assertGolden(t *testing.T, name string, actual *image.RGBA) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		f, err := os.Create(path)
+		assert.Nil(t, err)
+		defer f.Close()
+		assert.Nil(t, png.Encode(f, actual))
+		return
+	}
+	f, err := os.Open(path)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer f.Close()
+	expected, err := png.Decode(f)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, expected.Bounds(), actual.Bounds())
+	// Allow small differences in floating point arithmetic between platforms
+	var mismatched int
+	for y := expected.Bounds().Min.Y; y < expected.Bounds().Max.Y; y++ {
+		for x := expected.Bounds().Min.X; x < expected.Bounds().Max.X; x++ {
+			er, eg, eb, ea := expected.At(x, y).RGBA()
+			ar, ag, ab, aa := actual.At(x, y).RGBA()
+			if differ(er, ar) || differ(eg, ag) || differ(eb, ab) || differ(ea, aa) {
+				mismatched++
+			}
+		}
+	}
+	assert.LessOrEqual(t, mismatched, WIDTH*HEIGHT/200, "%s differs in %d pixels", name, mismatched)
+}
+
+func differ(a, b uint32) bool {
+	a >>= 8
+	b >>= 8
+	if a > b {
+		return a-b > TOLERANCE
+	}
+	return b-a > TOLERANCE
+}
@@ -0,0 +1,100 @@
+package perspectivefungo
+
+import (
+	"math"
+)
+
+// InputController turns the pointer and key events of a front-end into actions on a Game.
+// A drag rotates the maze and snaps it to the nearest axis when released, while a tap starts the game, releases the ball, or presses a button on the end screen.
+type InputController struct {
+	game     Game
+	bindings KeyBindings
+	OnShare  func(*Solution) // Called when the share button of a won game is pressed
+
+	scale, threshold float64
+	dragging         bool
+	rotated          bool
+	lastX, lastY     float64
+}
+
+func NewInputController(game Game, bindings KeyBindings) *InputController {
+	return &InputController{
+		game:     game,
+		bindings: bindings,
+	}
+}
+
+// Resize updates the size of the screen, which determines how far a drag rotates the maze.
+func (c *InputController) Resize(width, height float64) {
+	c.scale = math.Min(width, height)
+	// Movements smaller than this are taps rather than drags
+	c.threshold = c.scale / 100
+	c.game.Resize(float32(width), float32(height))
+}
+
+func (c *InputController) Down(x, y float64) {
+	if c.game.Animating() {
+		return
+	}
+	c.dragging = true
+	c.rotated = false
+	c.lastX, c.lastY = x, y
+}
+
+func (c *InputController) Move(x, y float64) {
+	if !c.dragging || c.game.Animating() {
+		return
+	}
+	deltaX := x - c.lastX
+	deltaY := y - c.lastY
+	if math.Abs(deltaX) <= c.threshold && math.Abs(deltaY) <= c.threshold {
+		// Don't update lastX or lastY
+		return
+	}
+	c.rotated = true
+	radX := float32((deltaY / c.scale) * 2.0 * math.Pi)
+	radY := float32((deltaX / c.scale) * 2.0 * math.Pi)
+	c.game.Rotate(radX, radY)
+	c.lastX, c.lastY = x, y
+}
+
+func (c *InputController) Up(x, y float64) {
+	dragging, rotated := c.dragging, c.rotated
+	c.dragging = false
+	c.rotated = false
+	if !dragging || c.game.Animating() {
+		return
+	}
+	if rotated {
+		c.game.RotateToAxis()
+	} else if !c.game.HasGameStarted() {
+		c.game.Start()
+	} else if c.game.HasGameEnded() {
+		switch c.game.HitTest(float32(x), float32(y)) {
+		case BUTTON_RETRY:
+			c.game.Reset()
+			c.game.Start()
+		case BUTTON_SHARE:
+			if s := c.game.Solution(); s != nil && c.OnShare != nil {
+				c.OnShare(s)
+			}
+		}
+	} else {
+		c.game.ReleaseBall()
+	}
+}
+
+// Cancel ends a drag without releasing the ball, as happens when the pointer leaves or is interrupted.
+func (c *InputController) Cancel() {
+	rotated := c.rotated
+	c.dragging = false
+	c.rotated = false
+	if rotated && !c.game.Animating() {
+		c.game.RotateToAxis()
+	}
+}
+
+// Key performs the action bound to the named key, and returns false if the key isn't bound.
+func (c *InputController) Key(name string) bool {
+	return c.bindings.Press(c.game, name)
+}
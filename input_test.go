@@ -0,0 +1,156 @@
+package perspectivefungo_test
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestInputController(t *testing.T) {
+	p := &perspectivefungo.Puzzle{
+		Size:   5,
+		Player: []int{0, 1, 0},
+		Goal:   []int{0, -1, 0},
+	}
+	// setup returns a controller for a 400x400 screen, with a game that has started
+	setup := func(t *testing.T) (*clockDriver, perspectivefungo.Game, *perspectivefungo.InputController) {
+		t.Helper()
+		d := &clockDriver{}
+		g := perspectivefungo.NewGame(p)
+		assert.Nil(t, d.Init(g))
+		c := perspectivefungo.NewInputController(g, perspectivefungo.DefaultKeyBindings())
+		c.Resize(400, 400)
+		c.Down(200, 200)
+		c.Up(200, 200)
+		assert.True(t, g.HasGameStarted())
+		return d, g, c
+	}
+	types := func(g perspectivefungo.Game) (ts []perspectivefungo.EventType) {
+		for _, e := range g.Solution().Events {
+			ts = append(ts, e.Type)
+		}
+		return
+	}
+	t.Run("Tap", func(t *testing.T) {
+		d, g, c := setup(t)
+		// Movement within the threshold is still a tap
+		c.Down(200, 200)
+		c.Move(202, 203)
+		c.Up(202, 203)
+		assert.Equal(t, []perspectivefungo.EventType{perspectivefungo.RELEASE_BALL}, types(g))
+		runSecond(t, d, g)
+		assert.True(t, g.HasGameEnded())
+	})
+	t.Run("Drag", func(t *testing.T) {
+		_, g, c := setup(t)
+		c.Down(200, 200)
+		c.Move(200, 300)
+		c.Move(300, 300)
+		c.Up(300, 300)
+		assert.True(t, g.Animating())
+		assert.Equal(t, []perspectivefungo.EventType{
+			perspectivefungo.ROTATE,
+			perspectivefungo.ROTATE_TO_AXIS,
+		}, types(g))
+		events := g.Solution().Events
+		// A drag across the whole screen is a full turn, and the movements of a drag are merged
+		assert.InDelta(t, math.Pi/2, events[0].X, 1e-6)
+		assert.InDelta(t, math.Pi/2, events[0].Y, 1e-6)
+
+		// Input is ignored until the maze is aligned
+		c.Down(200, 200)
+		c.Up(200, 200)
+		assert.Len(t, g.Solution().Events, 2)
+	})
+	t.Run("LongDrag", func(t *testing.T) {
+		d, g, c := setup(t)
+		// Drag back and forth for 5 minutes at 60 moves per second
+		for i := 0; i < 5*60*60; i++ {
+			if i%60 == 0 {
+				c.Down(200, 200)
+			}
+			c.Move(200+float64(i%60), 200)
+			if i%60 == 59 {
+				c.Up(200+float64(i%60), 200)
+				runSecond(t, d, g)
+			}
+		}
+		s := g.Solution()
+		assert.Len(t, s.Events, 2*5*60)
+		data, err := json.Marshal(s)
+		assert.Nil(t, err)
+		// Well under the size accepted by the server
+		assert.Less(t, len(data), 1<<16)
+	})
+	t.Run("Cancel", func(t *testing.T) {
+		d, g, c := setup(t)
+		c.Down(200, 200)
+		c.Move(200, 300)
+		c.Cancel()
+		assert.Equal(t, []perspectivefungo.EventType{
+			perspectivefungo.ROTATE,
+			perspectivefungo.ROTATE_TO_AXIS,
+		}, types(g))
+		runSecond(t, d, g)
+		// The drag is over, so moving and lifting does nothing
+		c.Move(300, 300)
+		c.Up(300, 300)
+		assert.Len(t, g.Solution().Events, 2)
+		// A cancelled tap does nothing
+		c.Down(200, 200)
+		c.Cancel()
+		assert.Len(t, g.Solution().Events, 2)
+	})
+	t.Run("Key", func(t *testing.T) {
+		_, g, c := setup(t)
+		assert.True(t, c.Key("space"))
+		assert.False(t, c.Key("q"))
+		assert.Equal(t, []perspectivefungo.EventType{perspectivefungo.RELEASE_BALL}, types(g))
+	})
+	t.Run("Retry", func(t *testing.T) {
+		d, g, c := setup(t)
+		var shared *perspectivefungo.Solution
+		c.OnShare = func(s *perspectivefungo.Solution) {
+			shared = s
+		}
+		c.Down(200, 200)
+		c.Up(200, 200)
+		runSecond(t, d, g)
+		assert.True(t, g.HasGameEnded())
+		solution := g.Solution()
+
+		// Tapping away from the buttons does nothing
+		c.Down(200, 10)
+		c.Up(200, 10)
+		assert.Nil(t, shared)
+		assert.True(t, g.HasGameEnded())
+
+		x, y, ok := find(g, 400, 400, perspectivefungo.BUTTON_SHARE)
+		assert.True(t, ok)
+		c.Down(x, y)
+		c.Up(x, y)
+		assert.Equal(t, solution, shared)
+		assert.True(t, g.HasGameEnded())
+
+		x, y, ok = find(g, 400, 400, perspectivefungo.BUTTON_RETRY)
+		assert.True(t, ok)
+		c.Down(x, y)
+		c.Up(x, y)
+		assert.False(t, g.HasGameEnded())
+		assert.Empty(t, g.Solution().Events)
+	})
+}
+
+// find searches the screen for a position that hits the button.
+func find(g perspectivefungo.Game, width, height int, id perspectivefungo.Button) (float64, float64, bool) {
+	for y := 0; y < height; y += 2 {
+		for x := 0; x < width; x += 2 {
+			if g.HitTest(float32(x), float32(y)) == id {
+				return float64(x), float64(y), true
+			}
+		}
+	}
+	return 0, 0, false
+}
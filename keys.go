@@ -0,0 +1,118 @@
+package perspectivefungo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+type Action string
+
+const (
+	ACTION_TURN_UP      Action = "turn_up"
+	ACTION_TURN_DOWN    Action = "turn_down"
+	ACTION_TURN_LEFT    Action = "turn_left"
+	ACTION_TURN_RIGHT   Action = "turn_right"
+	ACTION_RELEASE_BALL Action = "release_ball"
+	ACTION_RETRY        Action = "retry"
+	ACTION_UNDO         Action = "undo"
+	ACTION_REDO         Action = "redo"
+	ACTION_HINT         Action = "hint"
+)
+
+var Actions = []Action{
+	ACTION_TURN_UP,
+	ACTION_TURN_DOWN,
+	ACTION_TURN_LEFT,
+	ACTION_TURN_RIGHT,
+	ACTION_RELEASE_BALL,
+	ACTION_RETRY,
+	ACTION_UNDO,
+	ACTION_REDO,
+	ACTION_HINT,
+}
+
+// KeyBindings maps the name of a key to the action it performs.
+// Keys are named by their lowercase character, or one of space, enter, escape, up, down, left, and right.
+type KeyBindings map[string]Action
+
+func DefaultKeyBindings() KeyBindings {
+	return KeyBindings{
+		"up":    ACTION_TURN_UP,
+		"w":     ACTION_TURN_UP,
+		"down":  ACTION_TURN_DOWN,
+		"s":     ACTION_TURN_DOWN,
+		"left":  ACTION_TURN_LEFT,
+		"a":     ACTION_TURN_LEFT,
+		"right": ACTION_TURN_RIGHT,
+		"d":     ACTION_TURN_RIGHT,
+		"space": ACTION_RELEASE_BALL,
+		"r":     ACTION_RETRY,
+		"z":     ACTION_UNDO,
+		"y":     ACTION_REDO,
+		"h":     ACTION_HINT,
+	}
+}
+
+// LoadKeyBindings reads a JSON object of keys and actions which replace the defaults, an empty action removes the default for that key.
+func LoadKeyBindings(r io.Reader) (KeyBindings, error) {
+	bindings := DefaultKeyBindings()
+	if err := json.NewDecoder(r).Decode(&bindings); err != nil {
+		return nil, err
+	}
+	for k, a := range bindings {
+		if a == "" {
+			delete(bindings, k)
+		} else if !a.Valid() {
+			return nil, fmt.Errorf("Invalid Action: %s bound to %s", a, k)
+		}
+	}
+	return bindings, nil
+}
+
+func (a Action) Valid() bool {
+	for _, v := range Actions {
+		if a == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Press performs the action bound to the key, and returns false if the key isn't bound.
+func (b KeyBindings) Press(g Game, key string) bool {
+	a, ok := b[key]
+	if !ok {
+		return false
+	}
+	if g.Animating() {
+		// Ignore input until the animation completes, as with the pointer
+		return true
+	}
+	switch a {
+	case ACTION_TURN_UP:
+		g.Turn(-1, 0)
+	case ACTION_TURN_DOWN:
+		g.Turn(1, 0)
+	case ACTION_TURN_LEFT:
+		g.Turn(0, -1)
+	case ACTION_TURN_RIGHT:
+		g.Turn(0, 1)
+	case ACTION_RELEASE_BALL:
+		if !g.HasGameStarted() {
+			g.Start()
+		} else {
+			g.ReleaseBall()
+		}
+	case ACTION_RETRY:
+		g.Reset()
+		g.Start()
+	case ACTION_UNDO:
+		g.Undo()
+	case ACTION_REDO:
+		g.Redo()
+	case ACTION_HINT:
+		g.Hint()
+	}
+	return true
+}
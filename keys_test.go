@@ -0,0 +1,96 @@
+package perspectivefungo_test
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestLoadKeyBindings(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		b, err := perspectivefungo.LoadKeyBindings(strings.NewReader(`{}`))
+		assert.Nil(t, err)
+		assert.Equal(t, perspectivefungo.DefaultKeyBindings(), b)
+		for _, a := range b {
+			assert.True(t, a.Valid())
+		}
+	})
+	t.Run("Override", func(t *testing.T) {
+		b, err := perspectivefungo.LoadKeyBindings(strings.NewReader(`{"i":"turn_up","w":"","enter":"release_ball"}`))
+		assert.Nil(t, err)
+		assert.Equal(t, perspectivefungo.ACTION_TURN_UP, b["i"])
+		assert.Equal(t, perspectivefungo.ACTION_TURN_UP, b["up"])
+		assert.Equal(t, perspectivefungo.ACTION_RELEASE_BALL, b["enter"])
+		assert.Equal(t, perspectivefungo.ACTION_RELEASE_BALL, b["space"])
+		_, ok := b["w"]
+		assert.False(t, ok)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := perspectivefungo.LoadKeyBindings(strings.NewReader(`{"x":"explode"}`))
+		assert.NotNil(t, err)
+		_, err = perspectivefungo.LoadKeyBindings(strings.NewReader(`["x"]`))
+		assert.NotNil(t, err)
+	})
+}
+
+func TestKeyBindingsPress(t *testing.T) {
+	p := &perspectivefungo.Puzzle{
+		Size:   5,
+		Player: []int{0, 0, 0},
+		Goal:   []int{0, -2, 1},
+		Blocks: []int{0, -2, 0, 0, 0, 2},
+	}
+	d := &clockDriver{}
+	b := perspectivefungo.DefaultKeyBindings()
+	g := perspectivefungo.NewGame(p)
+	assert.Nil(t, d.Init(g))
+
+	assert.False(t, b.Press(g, "q"))
+
+	// Space starts the game before it releases the ball
+	assert.True(t, b.Press(g, "space"))
+	assert.True(t, g.HasGameStarted())
+	assert.Empty(t, g.Solution().Events)
+
+	// Turning down and back up returns to the start
+	assert.True(t, b.Press(g, "s"))
+	assert.True(t, g.Animating())
+	// Input is ignored while animating
+	assert.True(t, b.Press(g, "w"))
+	runSecond(t, d, g)
+	assert.Equal(t, [3]int{0, 0, 1}, g.Solution().Events[0].Orientation.Gravity())
+	assert.True(t, b.Press(g, "w"))
+	runSecond(t, d, g)
+	assert.Len(t, g.Solution().Events, 2)
+	assert.Equal(t, &perspectivefungo.Orientation{1, 0, 0, 0, 1, 0, 0, 0, 1}, g.Solution().Events[1].Orientation)
+
+	// Turning left or right keeps gravity pointing down
+	assert.True(t, b.Press(g, "right"))
+	runSecond(t, d, g)
+	assert.Equal(t, [3]int{0, -1, 0}, g.Solution().Events[2].Orientation.Gravity())
+	assert.True(t, b.Press(g, "a"))
+	runSecond(t, d, g)
+
+	// Fall onto the block behind, then down into the goal
+	assert.True(t, b.Press(g, "down"))
+	runSecond(t, d, g)
+	assert.True(t, b.Press(g, "space"))
+	runSecond(t, d, g)
+	assert.True(t, b.Press(g, "up"))
+	runSecond(t, d, g)
+	assert.True(t, b.Press(g, "space"))
+	runSecond(t, d, g)
+	assert.True(t, g.HasGameEnded())
+	s := g.Solution()
+	if !assert.NotNil(t, s) {
+		return
+	}
+	_, err := s.Replay(p)
+	assert.Nil(t, err)
+
+	// Retry starts again
+	assert.True(t, b.Press(g, "r"))
+	assert.True(t, g.HasGameStarted())
+	assert.False(t, g.HasGameEnded())
+}
@@ -0,0 +1,100 @@
+package perspectivefungo
+
+import (
+	"sort"
+	"time"
+)
+
+const LEADERBOARD_SIZE = 10
+
+// HistogramBuckets are the upper bounds of each bucket of solve times, the last bucket holds everything slower.
+var HistogramBuckets = []time.Duration{
+	15 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	2 * time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+}
+
+type Leaderboard struct {
+	Date      string   `json:"date"`
+	Solved    uint     `json:"solved"`            // Number of players who solved the puzzle, each counted once
+	Players   []string `json:"players,omitempty"` // Sorted IDs of the players counted in Solved
+	Fastest   []*Entry `json:"fastest"`
+	Fewest    []*Entry `json:"fewest"`
+	Histogram []uint   `json:"histogram"`
+}
+
+type Entry struct {
+	Duration  time.Duration `json:"duration"`
+	Rotations uint          `json:"rotations"`
+	Undos     uint          `json:"undos,omitempty"`
+}
+
+func NewLeaderboard(date time.Time) *Leaderboard {
+	return &Leaderboard{
+		Date:      date.UTC().Format(DATE_FORMAT),
+		Histogram: make([]uint, len(HistogramBuckets)+1),
+	}
+}
+
+// Add records a solve by the player, keeping only the fastest times and fewest rotations.
+// Each player is only counted once in Solved and the histogram, by the time of their first solve, while an empty player is counted every time.
+func (l *Leaderboard) Add(player string, duration time.Duration, rotations, undos uint) {
+	if l.addPlayer(player) {
+		l.Solved++
+		bucket := sort.Search(len(HistogramBuckets), func(i int) bool {
+			return duration <= HistogramBuckets[i]
+		})
+		if len(l.Histogram) <= bucket {
+			l.Histogram = append(l.Histogram, make([]uint, bucket+1-len(l.Histogram))...)
+		}
+		l.Histogram[bucket]++
+	}
+	e := &Entry{
+		Duration:  duration,
+		Rotations: rotations,
+		Undos:     undos,
+	}
+	l.Fastest = insert(l.Fastest, e, func(a, b *Entry) bool {
+		return a.Duration < b.Duration
+	})
+	l.Fewest = insert(l.Fewest, e, func(a, b *Entry) bool {
+		if a.Rotations == b.Rotations {
+			return a.Duration < b.Duration
+		}
+		return a.Rotations < b.Rotations
+	})
+}
+
+// addPlayer adds the player to the sorted list of players, and returns false if they were already there.
+func (l *Leaderboard) addPlayer(player string) bool {
+	if player == "" {
+		return true
+	}
+	i := sort.SearchStrings(l.Players, player)
+	if i < len(l.Players) && l.Players[i] == player {
+		return false
+	}
+	l.Players = append(l.Players, "")
+	copy(l.Players[i+1:], l.Players[i:])
+	l.Players[i] = player
+	return true
+}
+
+func insert(entries []*Entry, e *Entry, less func(a, b *Entry) bool) []*Entry {
+	i := sort.Search(len(entries), func(i int) bool {
+		return less(e, entries[i])
+	})
+	if i >= LEADERBOARD_SIZE {
+		return entries
+	}
+	entries = append(entries, nil)
+	copy(entries[i+1:], entries[i:])
+	entries[i] = e
+	if len(entries) > LEADERBOARD_SIZE {
+		entries = entries[:LEADERBOARD_SIZE]
+	}
+	return entries
+}
@@ -0,0 +1,62 @@
+package perspectivefungo_test
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLeaderboard(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		l := perspectivefungo.NewLeaderboard(time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC))
+		assert.Equal(t, "2026-11-01", l.Date)
+		assert.Equal(t, uint(0), l.Solved)
+		assert.Equal(t, len(perspectivefungo.HistogramBuckets)+1, len(l.Histogram))
+	})
+	t.Run("Ranked", func(t *testing.T) {
+		l := perspectivefungo.NewLeaderboard(time.Now())
+		l.Add("", 20*time.Second, 3, 0)
+		l.Add("", 10*time.Second, 4, 0)
+		l.Add("", 40*time.Second, 2, 0)
+		l.Add("", 30*time.Second, 2, 0)
+		assert.Equal(t, uint(4), l.Solved)
+		assert.Equal(t, []*perspectivefungo.Entry{
+			{Duration: 10 * time.Second, Rotations: 4},
+			{Duration: 20 * time.Second, Rotations: 3},
+			{Duration: 30 * time.Second, Rotations: 2},
+			{Duration: 40 * time.Second, Rotations: 2},
+		}, l.Fastest)
+		assert.Equal(t, []*perspectivefungo.Entry{
+			{Duration: 30 * time.Second, Rotations: 2},
+			{Duration: 40 * time.Second, Rotations: 2},
+			{Duration: 20 * time.Second, Rotations: 3},
+			{Duration: 10 * time.Second, Rotations: 4},
+		}, l.Fewest)
+		assert.Equal(t, []uint{1, 2, 1, 0, 0, 0, 0}, l.Histogram)
+	})
+	t.Run("Limited", func(t *testing.T) {
+		l := perspectivefungo.NewLeaderboard(time.Now())
+		for i := 0; i < 2*perspectivefungo.LEADERBOARD_SIZE; i++ {
+			l.Add("", time.Duration(2*perspectivefungo.LEADERBOARD_SIZE-i)*time.Second, 1, 0)
+		}
+		l.Add("", time.Hour, 1, 0)
+		assert.Equal(t, uint(2*perspectivefungo.LEADERBOARD_SIZE+1), l.Solved)
+		assert.Equal(t, perspectivefungo.LEADERBOARD_SIZE, len(l.Fastest))
+		assert.Equal(t, time.Second, l.Fastest[0].Duration)
+		assert.Equal(t, time.Duration(perspectivefungo.LEADERBOARD_SIZE)*time.Second, l.Fastest[perspectivefungo.LEADERBOARD_SIZE-1].Duration)
+		assert.Equal(t, uint(1), l.Histogram[len(l.Histogram)-1])
+	})
+	t.Run("Players", func(t *testing.T) {
+		l := perspectivefungo.NewLeaderboard(time.Now())
+		l.Add("bob", 20*time.Second, 3, 0)
+		l.Add("alice", 40*time.Second, 2, 0)
+		// Alice submits again, which ranks but isn't counted as another player
+		l.Add("alice", 10*time.Second, 2, 0)
+		assert.Equal(t, uint(2), l.Solved)
+		assert.Equal(t, []string{"alice", "bob"}, l.Players)
+		assert.Equal(t, []uint{0, 1, 1, 0, 0, 0, 0}, l.Histogram)
+		assert.Equal(t, 10*time.Second, l.Fastest[0].Duration)
+		assert.Len(t, l.Fastest, 3)
+	})
+}
@@ -0,0 +1,408 @@
+package perspectivefungo
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"sort"
+	"strconv"
+)
+
+type Outcome int
+
+const (
+	BLOCKED Outcome = iota
+	GOAL
+	OUT_OF_BOUNDS
+	LOOP
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case BLOCKED:
+		return "Blocked"
+	case GOAL:
+		return "Goal"
+	case OUT_OF_BOUNDS:
+		return "Out of Bounds"
+	case LOOP:
+		return "Loop"
+	}
+	return "Unknown"
+}
+
+// Maze is the immutable part of a puzzle, indexed for simulation.
+type Maze struct {
+	Size      uint
+	Balls     [][3]int
+	Goals     [][3]int // A goal shared by every ball, or one for each ball
+	Blocks    map[[3]int]bool
+	Crumbling map[[3]int]int // Index of each crumbling block in State.Crumbled
+	Portals   map[[3]int][3]int
+	Switches  map[[3]int][3]int
+	Keys      map[[3]int]int // Index of the lock opened by each key, and of its flag in State.Keys
+	Doors     map[[3]int]int // Index of the lock which opens each door
+}
+
+// NewMaze indexes a puzzle that should already have passed Validate, as incomplete coordinates, a portal without a partner, and missing links, switches, or locks are ignored rather than reported.
+func NewMaze(puzzle *Puzzle) *Maze {
+	m := &Maze{
+		Size:      puzzle.Size,
+		Blocks:    make(map[[3]int]bool),
+		Crumbling: make(map[[3]int]int),
+		Portals:   make(map[[3]int][3]int),
+		Switches:  make(map[[3]int][3]int),
+		Keys:      make(map[[3]int]int),
+		Doors:     make(map[[3]int]int),
+	}
+	for i := 0; i+2 < len(puzzle.Player); i += 3 {
+		m.Balls = append(m.Balls, [3]int{puzzle.Player[i], puzzle.Player[i+1], puzzle.Player[i+2]})
+	}
+	for i := 0; i+2 < len(puzzle.Goal); i += 3 {
+		m.Goals = append(m.Goals, [3]int{puzzle.Goal[i], puzzle.Goal[i+1], puzzle.Goal[i+2]})
+	}
+	for i := 0; i+2 < len(puzzle.Blocks); i += 3 {
+		m.Blocks[[3]int{puzzle.Blocks[i], puzzle.Blocks[i+1], puzzle.Blocks[i+2]}] = true
+	}
+	for i := 0; i+2 < len(puzzle.Crumbling); i += 3 {
+		m.Crumbling[[3]int{puzzle.Crumbling[i], puzzle.Crumbling[i+1], puzzle.Crumbling[i+2]}] = i / 3
+	}
+	for i := 0; i+5 < len(puzzle.Portals); i += 6 {
+		a := [3]int{puzzle.Portals[i], puzzle.Portals[i+1], puzzle.Portals[i+2]}
+		b := [3]int{puzzle.Portals[i+3], puzzle.Portals[i+4], puzzle.Portals[i+5]}
+		m.Portals[a] = b
+		m.Portals[b] = a
+	}
+	for _, l := range puzzle.Links {
+		if l == nil {
+			continue
+		}
+		m.Portals[l.From] = l.To
+		if l.Type == TWO_WAY {
+			m.Portals[l.To] = l.From
+		}
+	}
+	for _, s := range puzzle.Switches {
+		if s == nil {
+			continue
+		}
+		m.Switches[s.Cell] = s.Direction
+	}
+	for i, l := range puzzle.Locks {
+		if l == nil {
+			continue
+		}
+		m.Keys[l.Key] = i
+		for _, d := range l.Doors {
+			m.Doors[d] = i
+		}
+	}
+	return m
+}
+
+// State is the mutable part of a puzzle, which changes as the balls are released.
+type State struct {
+	Balls    [][3]int // Cell of each ball, a new slice is made whenever one moves
+	Home     []bool   // Whether each ball has reached a goal, after which it stays there out of play
+	Crumbled []bool   // Whether each crumbling block has crumbled away, a new slice is made whenever one does
+	Keys     []bool   // Whether the key of each lock has been collected, a new slice is made whenever one is
+}
+
+// StateKey identifies a state by value, so it can key maps without allocating for the common case of a single ball.
+type StateKey struct {
+	Ball     [3]int // Cell of the first ball
+	Home     uint64 // First 64 flags of each kind, one bit per flag
+	Crumbled uint64
+	Keys     uint64
+	Extra    string // Cells of any other balls, and any flags beyond the first 64, packed
+}
+
+func (s *State) Key() StateKey {
+	var (
+		key   StateKey
+		extra []byte
+	)
+	for i, b := range s.Balls {
+		if i == 0 {
+			key.Ball = b
+			continue
+		}
+		for _, c := range b {
+			extra = strconv.AppendInt(extra, int64(c), 10)
+			extra = append(extra, ',')
+		}
+	}
+	key.Home = pack(s.Home, 'h', &extra)
+	key.Crumbled = pack(s.Crumbled, 'c', &extra)
+	key.Keys = pack(s.Keys, 'k', &extra)
+	key.Extra = string(extra)
+	return key
+}
+
+// pack returns the first 64 flags as bits, and appends the index of any later flag that is set to extra.
+func pack(flags []bool, tag byte, extra *[]byte) uint64 {
+	var bits uint64
+	for i, f := range flags {
+		if !f {
+			continue
+		}
+		if i < 64 {
+			bits |= 1 << i
+		} else {
+			*extra = append(*extra, tag)
+			*extra = strconv.AppendInt(*extra, int64(i), 10)
+		}
+	}
+	return bits
+}
+
+type Step struct {
+	Cell      [3]int
+	Portal    bool // True if the cell was reached by teleporting through a portal
+	Switched  bool // True if the ball passed through a switch in the cell
+	Collected bool // True if the ball collected a key in the cell
+}
+
+type Fall struct {
+	Ball      int // Index of the ball which fell
+	Path      []*Step
+	Direction [3]int // The direction the ball was released in
+	Landing   [3]int // The direction the ball was falling when it stopped, which differs from Direction after a switch
+	Outcome   Outcome
+	Block     [3]int // The block or ball which stopped the ball, only set when Outcome is BLOCKED
+	Crumbled  bool   // True if the block which stopped the ball then crumbled away
+	State     *State
+	Balls     []*Fall // The fall of each ball in the order they were resolved, only set when there is more than one ball
+}
+
+// falls returns the fall of each ball.
+func (f *Fall) falls() []*Fall {
+	if f.Path != nil {
+		return []*Fall{f}
+	}
+	return f.Balls
+}
+
+func (m *Maze) Start() *State {
+	return &State{
+		Balls: append([][3]int(nil), m.Balls...),
+	}
+}
+
+// goal returns true if the ball finishes in the cell, which must be the shared goal or the ball's own.
+func (m *Maze) goal(ball int, cell [3]int) bool {
+	if len(m.Goals) == 1 {
+		return cell == m.Goals[0]
+	}
+	return ball < len(m.Goals) && cell == m.Goals[ball]
+}
+
+func (m *Maze) OutOfBounds(cell [3]int) bool {
+	return Abs(cell[0]) > m.Size || Abs(cell[1]) > m.Size || Abs(cell[2]) > m.Size
+}
+
+// Release simulates every ball in play falling together from the given state in the given direction.
+// Balls are resolved one at a time, starting with the ball furthest in the direction of the fall, so balls behind come to rest on balls ahead.
+// With a single ball the fall is that of the ball, otherwise the outcome is GOAL once every ball has reached a goal, OUT_OF_BOUNDS or LOOP if any ball does, and BLOCKED otherwise.
+func (m *Maze) Release(state *State, direction [3]int) *Fall {
+	var order []int
+	for i := range state.Balls {
+		if !flagged(state.Home, i) {
+			order = append(order, i)
+		}
+	}
+	// Ties are resolved in the order the balls are listed
+	sort.SliceStable(order, func(a, b int) bool {
+		return dot(state.Balls[order[a]], direction) > dot(state.Balls[order[b]], direction)
+	})
+	// Balls in play block each other
+	occupied := make(map[[3]int]bool)
+	for _, i := range order {
+		occupied[state.Balls[i]] = true
+	}
+	f := &Fall{
+		Direction: direction,
+		Outcome:   GOAL,
+		State:     state,
+	}
+	for _, i := range order {
+		delete(occupied, state.Balls[i])
+		b := m.release(f.State, i, direction, occupied, state.Crumbled)
+		f.Balls = append(f.Balls, b)
+		f.State = b.State
+		switch b.Outcome {
+		case BLOCKED:
+			occupied[b.State.Balls[i]] = true
+			if f.Outcome == GOAL {
+				f.Outcome = BLOCKED
+			}
+		case OUT_OF_BOUNDS, LOOP:
+			if f.Outcome == GOAL || f.Outcome == BLOCKED {
+				f.Outcome = b.Outcome
+			}
+		}
+	}
+	if len(state.Balls) == 1 && len(f.Balls) == 1 {
+		return f.Balls[0]
+	}
+	return f
+}
+
+// release simulates a single ball falling until it reaches a goal, is stopped by a block or another ball, falls out of bounds, or loops forever.
+// Switches passed through on the way change the direction for the rest of the fall, and keys collected on the way open their doors straight away.
+// Blocks only crumble once every ball has come to rest, so those crumbled by earlier balls still block later ones.
+func (m *Maze) release(state *State, ball int, direction [3]int, occupied map[[3]int]bool, crumbled []bool) *Fall {
+	cell := state.Balls[ball]
+	f := &Fall{
+		Ball: ball,
+		Path: []*Step{
+			{Cell: cell},
+		},
+		Direction: direction,
+	}
+	// The ball cannot teleport or switch in the cell it starts in
+	portaled := true
+	switched := true
+	keys := state.Keys
+	// Tracks the cells and directions seen to detect infinite loops
+	seen := make(map[pass]bool)
+	for {
+		if m.OutOfBounds(cell) {
+			f.Outcome = OUT_OF_BOUNDS
+			break
+		}
+		if m.goal(ball, cell) {
+			f.Outcome = GOAL
+			break
+		}
+		if !portaled {
+			if link, ok := m.Portals[cell]; ok {
+				cell = link
+				portaled = true
+				f.Path = append(f.Path, &Step{
+					Cell:   cell,
+					Portal: true,
+				})
+				continue
+			}
+		}
+		if !switched {
+			if d, ok := m.Switches[cell]; ok {
+				direction = d
+				f.Path[len(f.Path)-1].Switched = true
+			}
+			switched = true
+		}
+		if i, ok := m.Keys[cell]; ok && !flagged(keys, i) {
+			// Copy so earlier states don't have the key
+			collected := make([]bool, len(m.Keys))
+			copy(collected, keys)
+			collected[i] = true
+			keys = collected
+			f.Path[len(f.Path)-1].Collected = true
+			// Open doors may lead somewhere new from cells already seen
+			seen = make(map[pass]bool)
+		}
+		key := pass{cell, direction, portaled}
+		if seen[key] {
+			f.Outcome = LOOP
+			break
+		}
+		seen[key] = true
+		next := [3]int{
+			cell[0] + direction[0],
+			cell[1] + direction[1],
+			cell[2] + direction[2],
+		}
+		if m.Blocks[next] {
+			f.Outcome = BLOCKED
+			f.Block = next
+			break
+		}
+		if occupied[next] {
+			f.Outcome = BLOCKED
+			f.Block = next
+			break
+		}
+		if i, ok := m.Doors[next]; ok && !flagged(keys, i) {
+			f.Outcome = BLOCKED
+			f.Block = next
+			break
+		}
+		if i, ok := m.Crumbling[next]; ok && !flagged(crumbled, i) {
+			f.Outcome = BLOCKED
+			f.Block = next
+			f.Crumbled = true
+			break
+		}
+		cell = next
+		portaled = false
+		switched = false
+		f.Path = append(f.Path, &Step{
+			Cell: cell,
+		})
+	}
+	f.Landing = direction
+	f.State = &State{
+		Balls:    append([][3]int(nil), state.Balls...),
+		Home:     state.Home,
+		Crumbled: state.Crumbled,
+		Keys:     keys,
+	}
+	f.State.Balls[ball] = cell
+	if f.Outcome == GOAL {
+		f.State.Home = make([]bool, len(state.Balls))
+		copy(f.State.Home, state.Home)
+		f.State.Home[ball] = true
+	}
+	if f.Crumbled {
+		// Copy so earlier states still have the block
+		f.State.Crumbled = make([]bool, len(m.Crumbling))
+		copy(f.State.Crumbled, state.Crumbled)
+		f.State.Crumbled[m.Crumbling[f.Block]] = true
+	}
+	return f
+}
+
+// Gravity returns the axis of the maze which points closest to the bottom of the screen.
+func Gravity(rotation mgl32.Mat4) [3]int {
+	v := rotation.Inv().Mul4x1(mgl32.Vec4{0, -1, 0, 1})
+	var (
+		axis int
+		max  float32
+	)
+	for i := 0; i < 3; i++ {
+		if a := abs(v[i]); a > max {
+			axis = i
+			max = a
+		}
+	}
+	var g [3]int
+	if v[axis] < 0 {
+		g[axis] = -1
+	} else {
+		g[axis] = 1
+	}
+	return g
+}
+
+func Abs(a int) uint {
+	if a < 0 {
+		return uint(-a)
+	}
+	return uint(a)
+}
+
+func dot(a, b [3]int) int {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+// flagged returns true if the flag at the given index is set, treating missing flags as unset.
+func flagged(flags []bool, i int) bool {
+	return i < len(flags) && flags[i]
+}
+
+// pass is a cell the ball moved through, the direction it was falling, and whether it arrived through a portal.
+type pass struct {
+	cell      [3]int
+	direction [3]int
+	portaled  bool
+}
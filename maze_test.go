@@ -0,0 +1,331 @@
+package perspectivefungo_test
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMazeRelease(t *testing.T) {
+	down := [3]int{0, -1, 0}
+	t.Run("Goal", func(t *testing.T) {
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 1, 0},
+			Goal:   []int{0, -1, 0},
+		})
+		f := maze.Release(maze.Start(), down)
+		assert.Equal(t, perspectivefungo.GOAL, f.Outcome)
+		assert.Equal(t, []*perspectivefungo.Step{
+			{Cell: [3]int{0, 1, 0}},
+			{Cell: [3]int{0, 0, 0}},
+			{Cell: [3]int{0, -1, 0}},
+		}, f.Path)
+		assert.Equal(t, [3]int{0, -1, 0}, f.State.Balls[0])
+	})
+	t.Run("Blocked", func(t *testing.T) {
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 1, 0},
+			Goal:   []int{1, 0, 0},
+			Blocks: []int{0, -1, 0},
+		})
+		f := maze.Release(maze.Start(), down)
+		assert.Equal(t, perspectivefungo.BLOCKED, f.Outcome)
+		assert.Equal(t, [3]int{0, -1, 0}, f.Block)
+		assert.Equal(t, [3]int{0, 0, 0}, f.State.Balls[0])
+	})
+	t.Run("Portal", func(t *testing.T) {
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:    5,
+			Player:  []int{0, 1, 0},
+			Goal:    []int{1, -1, 0},
+			Portals: []int{0, -1, 0, 1, 1, 0},
+		})
+		f := maze.Release(maze.Start(), down)
+		assert.Equal(t, perspectivefungo.GOAL, f.Outcome)
+		assert.Equal(t, []*perspectivefungo.Step{
+			{Cell: [3]int{0, 1, 0}},
+			{Cell: [3]int{0, 0, 0}},
+			{Cell: [3]int{0, -1, 0}},
+			{Cell: [3]int{1, 1, 0}, Portal: true},
+			{Cell: [3]int{1, 0, 0}},
+			{Cell: [3]int{1, -1, 0}},
+		}, f.Path)
+	})
+	t.Run("OneWay", func(t *testing.T) {
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 1, 0},
+			Goal:   []int{1, -2, 0},
+			Links: []*perspectivefungo.Link{
+				{Type: perspectivefungo.ONE_WAY, From: [3]int{0, -1, 0}, To: [3]int{1, 1, 0}},
+			},
+			Blocks: []int{1, -1, 0, 0, -2, 0},
+		})
+		// The entrance teleports the ball to the exit
+		f := maze.Release(maze.Start(), down)
+		assert.Equal(t, perspectivefungo.BLOCKED, f.Outcome)
+		assert.Equal(t, []*perspectivefungo.Step{
+			{Cell: [3]int{0, 1, 0}},
+			{Cell: [3]int{0, 0, 0}},
+			{Cell: [3]int{0, -1, 0}},
+			{Cell: [3]int{1, 1, 0}, Portal: true},
+			{Cell: [3]int{1, 0, 0}},
+		}, f.Path)
+		// The exit is not an entrance
+		f = maze.Release(&perspectivefungo.State{Balls: [][3]int{{1, 2, 0}}}, down)
+		assert.Equal(t, perspectivefungo.BLOCKED, f.Outcome)
+		assert.Equal(t, [3]int{1, 0, 0}, f.State.Balls[0])
+		for _, s := range f.Path {
+			assert.False(t, s.Portal)
+		}
+	})
+	t.Run("TwoWay", func(t *testing.T) {
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{1, 2, 0},
+			Goal:   []int{0, -2, 0},
+			Links: []*perspectivefungo.Link{
+				{Type: perspectivefungo.TWO_WAY, From: [3]int{0, -1, 0}, To: [3]int{1, 1, 0}},
+			},
+		})
+		f := maze.Release(maze.Start(), down)
+		assert.Equal(t, perspectivefungo.GOAL, f.Outcome)
+		assert.Equal(t, []*perspectivefungo.Step{
+			{Cell: [3]int{1, 2, 0}},
+			{Cell: [3]int{1, 1, 0}},
+			{Cell: [3]int{0, -1, 0}, Portal: true},
+			{Cell: [3]int{0, -2, 0}},
+		}, f.Path)
+	})
+	t.Run("Crumbling", func(t *testing.T) {
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:      5,
+			Player:    []int{0, 2, 0},
+			Goal:      []int{0, -2, 0},
+			Crumbling: []int{0, 0, 0},
+		})
+		start := maze.Start()
+		// The block stops the ball once
+		f := maze.Release(start, down)
+		assert.Equal(t, perspectivefungo.BLOCKED, f.Outcome)
+		assert.Equal(t, [3]int{0, 1, 0}, f.State.Balls[0])
+		assert.Equal(t, [3]int{0, 0, 0}, f.Block)
+		assert.True(t, f.Crumbled)
+		assert.Equal(t, []bool{true}, f.State.Crumbled)
+		assert.NotEqual(t, start.Key(), (&perspectivefungo.State{Balls: start.Balls, Crumbled: f.State.Crumbled}).Key())
+		// Then crumbles away
+		f = maze.Release(f.State, down)
+		assert.Equal(t, perspectivefungo.GOAL, f.Outcome)
+		assert.False(t, f.Crumbled)
+		// Earlier states are unchanged
+		assert.Nil(t, start.Crumbled)
+		f = maze.Release(start, down)
+		assert.Equal(t, perspectivefungo.BLOCKED, f.Outcome)
+	})
+	t.Run("Lock", func(t *testing.T) {
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 2, 0},
+			Goal:   []int{2, -2, 0},
+			Locks: []*perspectivefungo.Lock{
+				{Key: [3]int{2, 2, 0}, Doors: [][3]int{{0, 0, 0}, {2, 0, 0}}},
+			},
+		})
+		start := maze.Start()
+		// The door blocks the ball until the key is collected
+		f := maze.Release(start, down)
+		assert.Equal(t, perspectivefungo.BLOCKED, f.Outcome)
+		assert.Equal(t, [3]int{0, 0, 0}, f.Block)
+		assert.Nil(t, f.State.Keys)
+		// Collect the key on the way past
+		f = maze.Release(start, [3]int{1, 0, 0})
+		assert.Equal(t, perspectivefungo.OUT_OF_BOUNDS, f.Outcome)
+		assert.True(t, f.Path[2].Collected)
+		assert.Equal(t, []bool{true}, f.State.Keys)
+		// The key opens every door straight away, even in the same fall
+		f = maze.Release(&perspectivefungo.State{Balls: [][3]int{{2, 2, 0}}, Keys: f.State.Keys}, down)
+		assert.Equal(t, perspectivefungo.GOAL, f.Outcome)
+		f = maze.Release(&perspectivefungo.State{Balls: [][3]int{{2, 3, 0}}}, down)
+		assert.Equal(t, perspectivefungo.GOAL, f.Outcome)
+		assert.True(t, f.Path[1].Collected)
+		// Earlier states don't have the key
+		assert.Nil(t, start.Keys)
+	})
+	t.Run("Switch", func(t *testing.T) {
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 2, 0},
+			Goal:   []int{1, -1, 0},
+			Blocks: []int{2, 0, 0},
+			Switches: []*perspectivefungo.GravitySwitch{
+				{Cell: [3]int{0, 0, 0}, Direction: [3]int{1, 0, 0}},
+			},
+		})
+		f := maze.Release(maze.Start(), down)
+		assert.Equal(t, perspectivefungo.BLOCKED, f.Outcome)
+		assert.Equal(t, []*perspectivefungo.Step{
+			{Cell: [3]int{0, 2, 0}},
+			{Cell: [3]int{0, 1, 0}},
+			{Cell: [3]int{0, 0, 0}, Switched: true},
+			{Cell: [3]int{1, 0, 0}},
+		}, f.Path)
+		assert.Equal(t, [3]int{2, 0, 0}, f.Block)
+		// The fall is still in the direction of release, but lands in the direction of the switch
+		assert.Equal(t, down, f.Direction)
+		assert.Equal(t, [3]int{1, 0, 0}, f.Landing)
+		// A switch has no effect on a ball starting in its cell
+		f = maze.Release(&perspectivefungo.State{Balls: [][3]int{{0, 0, 0}}}, down)
+		assert.Equal(t, perspectivefungo.OUT_OF_BOUNDS, f.Outcome)
+		assert.Equal(t, down, f.Landing)
+	})
+	t.Run("SwitchLoop", func(t *testing.T) {
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 2, 0},
+			Goal:   []int{1, -1, 0},
+			Switches: []*perspectivefungo.GravitySwitch{
+				{Cell: [3]int{0, 1, 0}, Direction: [3]int{0, -1, 0}},
+				{Cell: [3]int{0, -1, 0}, Direction: [3]int{0, 1, 0}},
+			},
+		})
+		f := maze.Release(maze.Start(), down)
+		assert.Equal(t, perspectivefungo.LOOP, f.Outcome)
+	})
+	t.Run("Balls", func(t *testing.T) {
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 2, 0, 0, 0, 0},
+			Goal:   []int{2, 2, 2},
+			Blocks: []int{0, -2, 0},
+		})
+		f := maze.Release(maze.Start(), down)
+		assert.Equal(t, perspectivefungo.BLOCKED, f.Outcome)
+		// The leading ball is resolved first, and the other lands on it
+		if assert.Len(t, f.Balls, 2) {
+			assert.Equal(t, 1, f.Balls[0].Ball)
+			assert.Equal(t, [3]int{0, -2, 0}, f.Balls[0].Block)
+			assert.Equal(t, 0, f.Balls[1].Ball)
+			assert.Equal(t, [3]int{0, -1, 0}, f.Balls[1].Block)
+		}
+		assert.Equal(t, [][3]int{{0, 0, 0}, {0, -1, 0}}, f.State.Balls)
+		// Released the other way, the first ball leads
+		f = maze.Release(f.State, [3]int{0, 1, 0})
+		assert.Equal(t, perspectivefungo.OUT_OF_BOUNDS, f.Outcome)
+		if assert.Len(t, f.Balls, 2) {
+			assert.Equal(t, 0, f.Balls[0].Ball)
+		}
+	})
+	t.Run("SharedGoal", func(t *testing.T) {
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 2, 0, 0, 1, 0, 1, 2, 0},
+			Goal:   []int{0, -2, 0},
+			Blocks: []int{1, -1, 0, -1, 0, 0},
+		})
+		f := maze.Release(maze.Start(), down)
+		// Balls in a goal are out of play, so don't block each other
+		assert.Equal(t, perspectivefungo.BLOCKED, f.Outcome)
+		assert.Equal(t, []bool{true, true, false}, f.State.Home)
+		assert.Equal(t, [][3]int{{0, -2, 0}, {0, -2, 0}, {1, 0, 0}}, f.State.Balls)
+		// Only the ball still in play moves
+		f = maze.Release(f.State, [3]int{-1, 0, 0})
+		assert.Equal(t, perspectivefungo.BLOCKED, f.Outcome)
+		assert.Len(t, f.Balls, 1)
+		assert.Equal(t, [3]int{0, 0, 0}, f.State.Balls[2])
+		// Once every ball is in the goal the puzzle is solved
+		f = maze.Release(f.State, down)
+		assert.Equal(t, perspectivefungo.GOAL, f.Outcome)
+		assert.Equal(t, []bool{true, true, true}, f.State.Home)
+		assert.Equal(t, [][3]int{{0, -2, 0}, {0, -2, 0}, {0, -2, 0}}, f.State.Balls)
+	})
+	t.Run("MatchedGoals", func(t *testing.T) {
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 2, 0, 1, 2, 0},
+			Goal:   []int{0, -2, 0, 1, -2, 0},
+		})
+		f := maze.Release(maze.Start(), down)
+		assert.Equal(t, perspectivefungo.GOAL, f.Outcome)
+		// Each ball only finishes in its own goal
+		maze = perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 2, 0, 1, 2, 0},
+			Goal:   []int{1, -2, 0, 0, -2, 0},
+		})
+		f = maze.Release(maze.Start(), down)
+		assert.Equal(t, perspectivefungo.OUT_OF_BOUNDS, f.Outcome)
+	})
+	t.Run("OutOfBounds", func(t *testing.T) {
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 1, 0},
+			Goal:   []int{1, -1, 0},
+		})
+		f := maze.Release(maze.Start(), down)
+		assert.Equal(t, perspectivefungo.OUT_OF_BOUNDS, f.Outcome)
+		assert.Equal(t, [3]int{0, -6, 0}, f.State.Balls[0])
+	})
+	t.Run("Loop", func(t *testing.T) {
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:    5,
+			Player:  []int{0, 0, 0},
+			Goal:    []int{1, -1, 0},
+			Portals: []int{0, -1, 0, 0, 1, 0},
+		})
+		f := maze.Release(maze.Start(), down)
+		assert.Equal(t, perspectivefungo.LOOP, f.Outcome)
+	})
+}
+
+func TestNewMaze(t *testing.T) {
+	t.Run("Unvalidated", func(t *testing.T) {
+		maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+			Size:     5,
+			Player:   []int{0, 1, 0},
+			Goal:     []int{0, -1, 0},
+			Blocks:   []int{1, 1, 1, 2},
+			Portals:  []int{-2, -2, -2, 2, 2, 2, 0, 0, 0},
+			Links:    []*perspectivefungo.Link{nil},
+			Switches: []*perspectivefungo.GravitySwitch{nil},
+			Locks:    []*perspectivefungo.Lock{nil},
+		})
+		assert.Equal(t, map[[3]int]bool{{1, 1, 1}: true}, maze.Blocks)
+		// The portal without a partner is ignored
+		assert.Equal(t, map[[3]int][3]int{
+			{-2, -2, -2}: {2, 2, 2},
+			{2, 2, 2}:    {-2, -2, -2},
+		}, maze.Portals)
+		f := maze.Release(maze.Start(), [3]int{0, -1, 0})
+		assert.Equal(t, perspectivefungo.GOAL, f.Outcome)
+	})
+}
+
+func TestStateKey(t *testing.T) {
+	state := func(crumbled int) *perspectivefungo.State {
+		s := &perspectivefungo.State{
+			Balls:    [][3]int{{0, 1, 0}, {1, 1, 0}},
+			Home:     []bool{false, true},
+			Crumbled: make([]bool, 100),
+		}
+		s.Crumbled[crumbled] = true
+		return s
+	}
+	// States are equal by value, not by the slices they hold
+	assert.Equal(t, state(3).Key(), state(3).Key())
+	assert.NotEqual(t, state(3).Key(), state(4).Key())
+	// Flags beyond the first 64 are still distinguished
+	assert.Equal(t, state(70).Key(), state(70).Key())
+	assert.NotEqual(t, state(70).Key(), state(71).Key())
+	moved := state(3)
+	moved.Balls[1] = [3]int{1, 0, 0}
+	assert.NotEqual(t, state(3).Key(), moved.Key())
+}
+
+func TestGravity(t *testing.T) {
+	assert.Equal(t, [3]int{0, -1, 0}, perspectivefungo.Gravity(mgl32.Ident4()))
+	assert.Equal(t, [3]int{-1, 0, 0}, perspectivefungo.Gravity(mgl32.HomogRotate3DZ(mgl32.DegToRad(90))))
+	assert.Equal(t, [3]int{0, 0, 1}, perspectivefungo.Gravity(mgl32.HomogRotate3DX(mgl32.DegToRad(90))))
+}
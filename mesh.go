@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
 	"math"
 	"strconv"
 	"strings"
@@ -171,3 +172,71 @@ func LoadOFFMesh(d Driver, id string, data []byte, smooth bool) error {
 	}
 	return nil
 }
+
+// Box is the axis-aligned box enclosing a mesh.
+type Box struct {
+	Min mgl32.Vec3
+	Max mgl32.Vec3
+}
+
+// Corners returns the eight vertices of the box.
+func (b *Box) Corners() []mgl32.Vec3 {
+	var corners []mgl32.Vec3
+	for _, x := range []float32{b.Min[0], b.Max[0]} {
+		for _, y := range []float32{b.Min[1], b.Max[1]} {
+			for _, z := range []float32{b.Min[2], b.Max[2]} {
+				corners = append(corners, mgl32.Vec3{x, y, z})
+			}
+		}
+	}
+	return corners
+}
+
+// OFFBox returns the box enclosing the vertices in the .OFF data.
+func OFFBox(data []byte) (*Box, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("Failed to read .OFF header")
+	}
+	header := strings.Fields(scanner.Text())
+	if len(header) < 3 || header[0] != "OFF" {
+		return nil, fmt.Errorf("Invalid .OFF header")
+	}
+	vertexCount, err := strconv.Atoi(header[1])
+	if err != nil {
+		return nil, err
+	}
+	var b *Box
+	for vertexCount > 0 && scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) == 0 {
+			continue
+		}
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("Invalid .OFF vertex")
+		}
+		var v mgl32.Vec3
+		for i, s := range parts[0:3] {
+			f, err := strconv.ParseFloat(s, 32)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = float32(f)
+		}
+		if b == nil {
+			b = &Box{Min: v, Max: v}
+		}
+		for i := 0; i < 3; i++ {
+			b.Min[i] = float32(math.Min(float64(b.Min[i]), float64(v[i])))
+			b.Max[i] = float32(math.Max(float64(b.Max[i]), float64(v[i])))
+		}
+		vertexCount--
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, fmt.Errorf("Empty .OFF mesh")
+	}
+	return b, nil
+}
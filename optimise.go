@@ -0,0 +1,226 @@
+package perspectivefungo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+type Objective int
+
+const (
+	MAXIMISE_ROTATIONS Objective = iota
+	MINIMISE_PENALTIES
+	TARGET_ROTATIONS
+)
+
+// Cost of a puzzle which cannot be solved, higher than any solvable puzzle
+const UNSOLVABLE_COST = 1000000
+
+func ParseObjective(s string) (Objective, error) {
+	switch s {
+	case "rotations":
+		return MAXIMISE_ROTATIONS, nil
+	case "penalties":
+		return MINIMISE_PENALTIES, nil
+	case "target":
+		return TARGET_ROTATIONS, nil
+	}
+	return 0, fmt.Errorf("Unrecognized objective: %s", s)
+}
+
+func (o Objective) String() string {
+	switch o {
+	case MAXIMISE_ROTATIONS:
+		return "rotations"
+	case MINIMISE_PENALTIES:
+		return "penalties"
+	case TARGET_ROTATIONS:
+		return "target"
+	}
+	return "unknown"
+}
+
+// Cost returns how far a puzzle is from the objective, lower is better.
+func (o Objective) Cost(solved bool, rotations, penalties, target uint) float64 {
+	if !solved {
+		return UNSOLVABLE_COST
+	}
+	r := float64(rotations)
+	p := float64(penalties)
+	switch o {
+	case MAXIMISE_ROTATIONS:
+		// Break ties with penalties
+		return -r + p/100
+	case MINIMISE_PENALTIES:
+		// Break ties with rotations
+		return p - r/100
+	case TARGET_ROTATIONS:
+		return math.Abs(r-float64(target)) + p/100
+	}
+	return UNSOLVABLE_COST
+}
+
+type OptimiserOptions struct {
+	Objective   Objective
+	Target      uint    // Rotations to aim for with TARGET_ROTATIONS
+	MaxBlocks   uint    // Maximum number of blocks, zero for no limit
+	Iterations  uint64  // Number of mutations to try
+	Temperature float64 // Initial temperature, which cools linearly to zero
+	Seed        int64
+	Interval    time.Duration // How often progress is reported
+}
+
+type OptimiserProgress struct {
+	Iteration uint64
+	Elapsed   time.Duration
+	Best      *Puzzle
+	Cost      float64
+	Rotations uint
+	Penalties uint
+}
+
+// Optimise improves the puzzle by simulated annealing; repeatedly mutating it, rescoring it, and keeping the mutation if it is better, or with a probability that decreases as it cools if it is worse.
+// The best puzzle seen is returned when the iterations are exhausted or the context is done.
+func Optimise(ctx context.Context, puzzle *Puzzle, options *OptimiserOptions, progress func(*OptimiserProgress)) (*Puzzle, error) {
+	if err := puzzle.Validate(); err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(options.Seed))
+	start := time.Now()
+	last := start
+
+	cost := func(p *Puzzle) (float64, uint, uint) {
+		goal, penalties := search(NewMaze(p), p)
+		var rotations uint
+		if goal != nil {
+			rotations = goal.rotations
+		}
+		return options.Objective.Cost(goal != nil, rotations, penalties, options.Target), rotations, penalties
+	}
+
+	current := puzzle.clone()
+	// Mutated puzzles cannot be regenerated from a seed, so record the seeds they came from instead
+	current.Seed = nil
+	current.Optimised = &Optimisation{
+		Source: puzzle.Seed,
+		Seed:   options.Seed,
+	}
+	currentCost, rotations, penalties := cost(current)
+	best := &OptimiserProgress{
+		Best:      current,
+		Cost:      currentCost,
+		Rotations: rotations,
+		Penalties: penalties,
+	}
+
+	report := func(i uint64) {
+		if progress == nil {
+			return
+		}
+		p := *best
+		p.Iteration = i
+		p.Elapsed = time.Since(start)
+		progress(&p)
+	}
+
+	var i uint64
+	for ; i < options.Iterations && ctx.Err() == nil; i++ {
+		if options.Interval > 0 && time.Since(last) >= options.Interval {
+			last = time.Now()
+			report(i)
+		}
+		next := mutate(rng, current, options.MaxBlocks)
+		if next == nil {
+			continue
+		}
+		nextCost, rotations, penalties := cost(next)
+		delta := nextCost - currentCost
+		temperature := options.Temperature * (1 - float64(i)/float64(options.Iterations))
+		if delta <= 0 || (temperature > 0 && rng.Float64() < math.Exp(-delta/temperature)) {
+			current = next
+			currentCost = nextCost
+			if currentCost < best.Cost {
+				best.Best = current
+				best.Cost = currentCost
+				best.Rotations = rotations
+				best.Penalties = penalties
+			}
+		}
+	}
+	report(i)
+	return best.Best, nil
+}
+
+// mutate returns a copy of the puzzle with a block moved, added, or removed, or a portal moved, or nil if the mutation produced an invalid puzzle.
+func mutate(rng *rand.Rand, puzzle *Puzzle, maxBlocks uint) *Puzzle {
+	p := puzzle.clone()
+	occupied := make(map[string]bool)
+	for _, cells := range [][]int{p.Player, p.Goal, p.Blocks, p.Crumbling, p.Portals, p.linkCells(), p.switchCells(), p.lockCells()} {
+		for i := 0; i+2 < len(cells); i += 3 {
+			occupied[Key(cells[i], cells[i+1], cells[i+2])] = true
+		}
+	}
+	// Moves the cell to a neighbouring cell, or anywhere in the puzzle, returning false if there is nowhere to move to
+	move := func(cell []int) bool {
+		if rng.Intn(2) == 0 {
+			d := directions[rng.Intn(len(directions))]
+			for i := 0; i < 3; i++ {
+				cell[i] += d[i]
+			}
+			return true
+		}
+		l, err := GenerateLocation(rng, occupied, p.Size)
+		if err != nil {
+			return false
+		}
+		copy(cell, l)
+		return true
+	}
+	blocks := len(p.Blocks) / 3
+	portals := len(p.Portals) / 3
+	switch rng.Intn(4) {
+	case 0:
+		// Move a block
+		if blocks == 0 {
+			return nil
+		}
+		b := rng.Intn(blocks) * 3
+		if !move(p.Blocks[b : b+3]) {
+			return nil
+		}
+	case 1:
+		// Add a block
+		if maxBlocks > 0 && uint(blocks) >= maxBlocks {
+			return nil
+		}
+		l, err := GenerateLocation(rng, occupied, p.Size)
+		if err != nil {
+			// Every cell is occupied
+			return nil
+		}
+		p.Blocks = append(p.Blocks, l...)
+	case 2:
+		// Remove a block
+		if blocks == 0 {
+			return nil
+		}
+		b := rng.Intn(blocks) * 3
+		p.Blocks = append(p.Blocks[:b], p.Blocks[b+3:]...)
+	case 3:
+		// Move a portal
+		if portals == 0 {
+			return nil
+		}
+		e := rng.Intn(portals) * 3
+		if !move(p.Portals[e : e+3]) {
+			return nil
+		}
+	}
+	if p.Validate() != nil {
+		return nil
+	}
+	return p
+}
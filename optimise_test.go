@@ -0,0 +1,72 @@
+package perspectivefungo_test
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOptimise(t *testing.T) {
+	for name, objective := range map[string]perspectivefungo.Objective{
+		"Rotations": perspectivefungo.MAXIMISE_ROTATIONS,
+		"Penalties": perspectivefungo.MINIMISE_PENALTIES,
+		"Target":    perspectivefungo.TARGET_ROTATIONS,
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, err := perspectivefungo.Generate(1234, 5, 4, 2)
+			assert.Nil(t, err)
+			options := &perspectivefungo.OptimiserOptions{
+				Objective:   objective,
+				Target:      3,
+				MaxBlocks:   8,
+				Iterations:  200,
+				Temperature: 1,
+				Seed:        1,
+			}
+			var final *perspectivefungo.OptimiserProgress
+			a, err := perspectivefungo.Optimise(context.Background(), p, options, func(p *perspectivefungo.OptimiserProgress) {
+				final = p
+			})
+			assert.Nil(t, err)
+			assert.Nil(t, a.Validate())
+			assert.LessOrEqual(t, len(a.Blocks)/3, 8)
+			assert.Equal(t, a, final.Best)
+			assert.Equal(t, uint64(200), final.Iteration)
+			rotations, penalties, _ := perspectivefungo.Score(a)
+			assert.Equal(t, rotations, final.Rotations)
+			assert.Equal(t, penalties, final.Penalties)
+			assert.Less(t, final.Cost, float64(perspectivefungo.UNSOLVABLE_COST))
+
+			// The seeds are recorded, as the puzzle can't be regenerated from its own
+			assert.Nil(t, a.Seed)
+			if assert.NotNil(t, a.Optimised) && assert.NotNil(t, a.Optimised.Source) {
+				assert.Equal(t, int64(1234), *a.Optimised.Source)
+				assert.Equal(t, int64(1), a.Optimised.Seed)
+			}
+
+			// Optimisation is reproducible
+			b, err := perspectivefungo.Optimise(context.Background(), p, options, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, a, b)
+		})
+	}
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := perspectivefungo.Optimise(context.Background(), &perspectivefungo.Puzzle{}, &perspectivefungo.OptimiserOptions{}, nil)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestParseObjective(t *testing.T) {
+	for _, o := range []perspectivefungo.Objective{
+		perspectivefungo.MAXIMISE_ROTATIONS,
+		perspectivefungo.MINIMISE_PENALTIES,
+		perspectivefungo.TARGET_ROTATIONS,
+	} {
+		p, err := perspectivefungo.ParseObjective(o.String())
+		assert.Nil(t, err)
+		assert.Equal(t, o, p)
+	}
+	_, err := perspectivefungo.ParseObjective("foo")
+	assert.NotNil(t, err)
+}
@@ -1,15 +1,349 @@
 package perspectivefungo
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
 type Puzzle struct {
-	Size    uint  `json:"size"`
-	Player  []int `json:"player"`
-	Goal    []int `json:"goal"`
-	Blocks  []int `json:"blocks"`
-	Portals []int `json:"portals"`
+	Size      uint             `json:"size"`
+	Player    []int            `json:"player"`
+	Goal      []int            `json:"goal"`
+	Blocks    []int            `json:"blocks"`
+	Crumbling []int            `json:"crumbling,omitempty"` // Blocks which stop the ball once, then crumble away
+	Portals   []int            `json:"portals"`
+	Links     []*Link          `json:"links,omitempty"`
+	Switches  []*GravitySwitch `json:"switches,omitempty"`
+	Locks     []*Lock          `json:"locks,omitempty"`
+	Seed      *int64           `json:"seed,omitempty"`      // Seed the puzzle was generated from, nil if it wasn't generated from one, so seed 0 is still written
+	Optimised *Optimisation    `json:"optimised,omitempty"` // How the puzzle was optimised, nil if it wasn't
+}
+
+// Optimisation records the seeds an optimised puzzle came from, as it can no longer be generated from a seed alone.
+type Optimisation struct {
+	Source *int64 `json:"source,omitempty"` // Seed of the puzzle that was optimised, nil if it wasn't generated from one
+	Seed   int64  `json:"seed"`             // Seed of the optimiser's mutations
+}
+
+type LinkType string
+
+const (
+	TWO_WAY LinkType = "two_way"
+	ONE_WAY LinkType = "one_way"
+)
+
+// Link is a portal between two cells, one way links only let the ball enter at From and leave at To.
+type Link struct {
+	Type LinkType `json:"type"`
+	From [3]int   `json:"from"`
+	To   [3]int   `json:"to"`
+}
+
+// linkCells returns the coordinates of both ends of every link.
+func (p *Puzzle) linkCells() []int {
+	var cells []int
+	for _, l := range p.Links {
+		if l == nil {
+			continue
+		}
+		cells = append(cells, l.From[:]...)
+		cells = append(cells, l.To[:]...)
+	}
+	return cells
+}
+
+// GravitySwitch is a cell which changes the direction of the falling ball to Direction for the rest of the fall.
+type GravitySwitch struct {
+	Cell      [3]int `json:"cell"`
+	Direction [3]int `json:"direction"`
+}
+
+// switchCells returns the coordinates of every switch.
+func (p *Puzzle) switchCells() []int {
+	var cells []int
+	for _, s := range p.Switches {
+		if s == nil {
+			continue
+		}
+		cells = append(cells, s.Cell[:]...)
+	}
+	return cells
+}
+
+// Lock is a key which, once collected by the ball, opens the doors that otherwise block it like a block.
+type Lock struct {
+	Key   [3]int   `json:"key"`
+	Doors [][3]int `json:"doors"`
+}
+
+// lockCells returns the coordinates of every key and door.
+func (p *Puzzle) lockCells() []int {
+	var cells []int
+	for _, l := range p.Locks {
+		if l == nil {
+			continue
+		}
+		cells = append(cells, l.Key[:]...)
+		for _, d := range l.Doors {
+			cells = append(cells, d[:]...)
+		}
+	}
+	return cells
+}
+
+// Pairs returns the number of portal pairs coloured by PortalColors, including two way links.
+func (p *Puzzle) Pairs() int {
+	pairs := len(p.Portals) / 6
+	for _, l := range p.Links {
+		if l != nil && l.Type == TWO_WAY {
+			pairs++
+		}
+	}
+	return pairs
+}
+
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	var ss []string
+	for _, err := range e {
+		ss = append(ss, err.Error())
+	}
+	return strings.Join(ss, "; ")
+}
+
+// Validate checks the puzzle is well formed, and returns ValidationErrors describing every problem found.
+func (p *Puzzle) Validate() error {
+	var errs ValidationErrors
+	report := func(field, format string, args ...interface{}) {
+		errs = append(errs, &ValidationError{
+			Field:   field,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if p.Size == 0 {
+		report("size", "Must be greater than zero")
+	}
+	min, max := Bounds(p.Size)
+
+	// Maps each occupied cell to the field occupying it
+	occupied := make(map[string]string)
+	check := func(field string, cell []int) {
+		for _, c := range cell {
+			if c < min || c > max {
+				report(field, "Cell %v is outside puzzle of size %d", cell, p.Size)
+				break
+			}
+		}
+		key := Key(cell[0], cell[1], cell[2])
+		if other, ok := occupied[key]; ok {
+			report(field, "Cell %v overlaps with %s", cell, other)
+			return
+		}
+		occupied[key] = field
+	}
+
+	// Player and Goal may list several balls and goals, but fields are only indexed when they do
+	cells := func(field string, values []int) {
+		if l := len(values); l == 0 || l%3 != 0 {
+			report(field, "Must have 3 coordinates, found %d", l)
+			return
+		}
+		for i := 0; i < len(values); i += 3 {
+			f := field
+			if len(values) > 3 {
+				f = fmt.Sprintf("%s[%d]", field, i/3)
+			}
+			check(f, values[i:i+3])
+		}
+	}
+
+	cells("goal", p.Goal)
+	cells("player", p.Player)
+
+	balls, goals := len(p.Player)/3, len(p.Goal)/3
+	if balls > len(BallColors) {
+		report("player", "Must have at most %d balls, found %d", len(BallColors), balls)
+	}
+	if goals > 1 && goals != balls {
+		report("goal", "Must have one goal, or one for each of the %d balls, found %d", balls, goals)
+	}
+
+	if l := len(p.Blocks); l%3 != 0 {
+		report("blocks", "Must have 3 coordinates per block, found %d coordinates", l)
+	}
+	for i := 0; i+2 < len(p.Blocks); i += 3 {
+		check(fmt.Sprintf("blocks[%d]", i/3), p.Blocks[i:i+3])
+	}
+
+	if l := len(p.Crumbling); l%3 != 0 {
+		report("crumbling", "Must have 3 coordinates per block, found %d coordinates", l)
+	}
+	for i := 0; i+2 < len(p.Crumbling); i += 3 {
+		check(fmt.Sprintf("crumbling[%d]", i/3), p.Crumbling[i:i+3])
+	}
+
+	if l := len(p.Portals); l%3 != 0 {
+		report("portals", "Must have 3 coordinates per portal, found %d coordinates", l)
+	}
+	count := len(p.Portals) / 3
+	for i := 0; i < count; i++ {
+		check(fmt.Sprintf("portals[%d]", i), p.Portals[i*3:i*3+3])
+	}
+	if count%2 != 0 {
+		report(fmt.Sprintf("portals[%d]", count-1), "Portal has no partner")
+	}
+
+	var oneWay int
+	for i, l := range p.Links {
+		field := fmt.Sprintf("links[%d]", i)
+		if l == nil {
+			report(field, "Missing")
+			continue
+		}
+		switch l.Type {
+		case TWO_WAY:
+		case ONE_WAY:
+			oneWay++
+		default:
+			report(field, "Unknown type %q", l.Type)
+		}
+		check(field, l.From[:])
+		check(field, l.To[:])
+	}
+
+	for i, s := range p.Switches {
+		field := fmt.Sprintf("switches[%d]", i)
+		if s == nil {
+			report(field, "Missing")
+			continue
+		}
+		check(field, s.Cell[:])
+		if Abs(s.Direction[0])+Abs(s.Direction[1])+Abs(s.Direction[2]) != 1 {
+			report(field, "Direction %v is not an axis", s.Direction)
+		}
+	}
+
+	for i, l := range p.Locks {
+		field := fmt.Sprintf("locks[%d]", i)
+		if l == nil {
+			report(field, "Missing")
+			continue
+		}
+		check(field, l.Key[:])
+		if len(l.Doors) == 0 {
+			report(field, "Must have at least one door")
+		}
+		for _, d := range l.Doors {
+			check(field, d[:])
+		}
+	}
+	if l := len(p.Locks); l > len(LockColors) {
+		report("locks", "Must have at most %d locks, found %d", len(LockColors), l)
+	}
+
+	if pairs := p.Pairs(); pairs > len(PortalColors) {
+		report("portals", "Must have at most %d pairs, found %d", len(PortalColors), pairs)
+	}
+	if oneWay > len(OneWayColors) {
+		report("links", "Must have at most %d one way links, found %d", len(OneWayColors), oneWay)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (p *Puzzle) clone() *Puzzle {
+	var links []*Link
+	for _, l := range p.Links {
+		c := *l
+		links = append(links, &c)
+	}
+	var locks []*Lock
+	for _, l := range p.Locks {
+		locks = append(locks, &Lock{
+			Key:   l.Key,
+			Doors: append([][3]int(nil), l.Doors...),
+		})
+	}
+	var switches []*GravitySwitch
+	for _, s := range p.Switches {
+		c := *s
+		switches = append(switches, &c)
+	}
+	return &Puzzle{
+		Size:      p.Size,
+		Player:    append([]int(nil), p.Player...),
+		Goal:      append([]int(nil), p.Goal...),
+		Blocks:    append([]int(nil), p.Blocks...),
+		Crumbling: append([]int(nil), p.Crumbling...),
+		Portals:   append([]int(nil), p.Portals...),
+		Links:     links,
+		Switches:  switches,
+		Locks:     locks,
+		Seed:      p.Seed,
+		Optimised: p.Optimised,
+	}
+}
+
+func ReadPuzzle(name string) (*Puzzle, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var p Puzzle
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// WritePuzzle writes the puzzle to a temporary file and then renames it, so readers never see a partially written puzzle.
+func WritePuzzle(name string, p *Puzzle) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name))
+	if err != nil {
+		return err
+	}
+	if err := temp.Chmod(0644); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return os.Rename(temp.Name(), name)
+}
+
+// Bounds returns the minimum and maximum coordinate of a cell inside a puzzle of the given size.
+func Bounds(size uint) (int, int) {
+	s := int(size)
+	return -s / 2, s - 1 - s/2
 }
 
 func Key(x, y, z int) string {
@@ -0,0 +1,246 @@
+package perspectivefungo_test
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPuzzleValidate(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		p := &perspectivefungo.Puzzle{
+			Size:    5,
+			Player:  []int{0, 2, 0},
+			Goal:    []int{0, -2, 0},
+			Blocks:  []int{1, 1, 1},
+			Portals: []int{-2, -2, -2, 2, 2, 2},
+		}
+		assert.Nil(t, p.Validate())
+	})
+	t.Run("Links", func(t *testing.T) {
+		p := &perspectivefungo.Puzzle{
+			Size:    5,
+			Player:  []int{0, 2, 0},
+			Goal:    []int{0, -2, 0},
+			Portals: []int{-2, -2, -2, 2, 2, 2},
+			Links: []*perspectivefungo.Link{
+				{Type: perspectivefungo.ONE_WAY, From: [3]int{1, 0, 0}, To: [3]int{-1, 0, 0}},
+				{Type: perspectivefungo.TWO_WAY, From: [3]int{1, 1, 0}, To: [3]int{-1, 1, 0}},
+			},
+		}
+		assert.Nil(t, p.Validate())
+		assert.Equal(t, 2, p.Pairs())
+	})
+	for name, tt := range map[string]struct {
+		puzzle *perspectivefungo.Puzzle
+		errors []string
+	}{
+		"Size": {
+			puzzle: &perspectivefungo.Puzzle{
+				Player: []int{0, 0, 0},
+				Goal:   []int{0, -1, 0},
+			},
+			errors: []string{
+				"size: Must be greater than zero",
+				"goal: Cell [0 -1 0] is outside puzzle of size 0",
+				"player: Cell [0 0 0] is outside puzzle of size 0",
+			},
+		},
+		"Coordinates": {
+			puzzle: &perspectivefungo.Puzzle{
+				Size:   5,
+				Player: []int{0, 1},
+				Goal:   []int{0, -1, 0},
+				Blocks: []int{1, 1, 1, 2},
+			},
+			errors: []string{
+				"player: Must have 3 coordinates, found 2",
+				"blocks: Must have 3 coordinates per block, found 4 coordinates",
+			},
+		},
+		"OutOfBounds": {
+			puzzle: &perspectivefungo.Puzzle{
+				Size:   4,
+				Player: []int{0, 2, 0},
+				Goal:   []int{0, -2, 0},
+			},
+			errors: []string{
+				"player: Cell [0 2 0] is outside puzzle of size 4",
+			},
+		},
+		"PlayerOnGoal": {
+			puzzle: &perspectivefungo.Puzzle{
+				Size:   5,
+				Player: []int{0, -1, 0},
+				Goal:   []int{0, -1, 0},
+			},
+			errors: []string{
+				"player: Cell [0 -1 0] overlaps with goal",
+			},
+		},
+		"Overlap": {
+			puzzle: &perspectivefungo.Puzzle{
+				Size:    5,
+				Player:  []int{0, 1, 0},
+				Goal:    []int{0, -1, 0},
+				Blocks:  []int{1, 1, 1, 1, 1, 1},
+				Portals: []int{0, 1, 0, 2, 2, 2},
+			},
+			errors: []string{
+				"blocks[1]: Cell [1 1 1] overlaps with blocks[0]",
+				"portals[0]: Cell [0 1 0] overlaps with player",
+			},
+		},
+		"Portals": {
+			puzzle: &perspectivefungo.Puzzle{
+				Size:    5,
+				Player:  []int{0, 1, 0},
+				Goal:    []int{0, -1, 0},
+				Portals: []int{-2, -2, -2, -1, -2, -2, 0, -2, -2, 1, -2, -2, 2, -2, -2, -2, -1, -2, -1, -1, -2, 0, -1, -2, 1, -1, -2},
+			},
+			errors: []string{
+				"portals[8]: Portal has no partner",
+				"portals: Must have at most 3 pairs, found 4",
+			},
+		},
+		"Links": {
+			puzzle: &perspectivefungo.Puzzle{
+				Size:    5,
+				Player:  []int{0, 1, 0},
+				Goal:    []int{0, -1, 0},
+				Portals: []int{-2, -2, -2, -1, -2, -2, 0, -2, -2, 1, -2, -2},
+				Links: []*perspectivefungo.Link{
+					{Type: "sideways", From: [3]int{2, 2, 2}, To: [3]int{2, 2, 1}},
+					{Type: perspectivefungo.ONE_WAY, From: [3]int{0, 1, 0}, To: [3]int{2, 2, 0}},
+					{Type: perspectivefungo.ONE_WAY, From: [3]int{1, 0, 0}, To: [3]int{3, 0, 0}},
+					{Type: perspectivefungo.TWO_WAY, From: [3]int{1, 1, 1}, To: [3]int{1, 1, -1}},
+					{Type: perspectivefungo.TWO_WAY, From: [3]int{1, 2, 1}, To: [3]int{1, 2, -1}},
+					nil,
+				},
+			},
+			errors: []string{
+				`links[0]: Unknown type "sideways"`,
+				"links[1]: Cell [0 1 0] overlaps with player",
+				"links[2]: Cell [3 0 0] is outside puzzle of size 5",
+				"links[5]: Missing",
+				"portals: Must have at most 3 pairs, found 4",
+			},
+		},
+		"Balls": {
+			puzzle: &perspectivefungo.Puzzle{
+				Size:   5,
+				Player: []int{0, 1, 0, 1, 1, 0, 0, 1, 0, 2, 2, 2},
+				Goal:   []int{0, -1, 0, 1, -1, 0},
+			},
+			errors: []string{
+				"player[2]: Cell [0 1 0] overlaps with player[0]",
+				"player: Must have at most 3 balls, found 4",
+				"goal: Must have one goal, or one for each of the 4 balls, found 2",
+			},
+		},
+		"Crumbling": {
+			puzzle: &perspectivefungo.Puzzle{
+				Size:      5,
+				Player:    []int{0, 1, 0},
+				Goal:      []int{0, -1, 0},
+				Blocks:    []int{1, 1, 1},
+				Crumbling: []int{0, 0, 0, 1, 1, 1, 2},
+			},
+			errors: []string{
+				"crumbling: Must have 3 coordinates per block, found 7 coordinates",
+				"crumbling[1]: Cell [1 1 1] overlaps with blocks[0]",
+			},
+		},
+		"Locks": {
+			puzzle: &perspectivefungo.Puzzle{
+				Size:   5,
+				Player: []int{0, 1, 0},
+				Goal:   []int{0, -1, 0},
+				Locks: []*perspectivefungo.Lock{
+					{Key: [3]int{1, 0, 0}, Doors: [][3]int{{0, 0, 0}}},
+					{Key: [3]int{2, 0, 0}},
+					{Key: [3]int{-1, 0, 0}, Doors: [][3]int{{1, 0, 0}}},
+					{Key: [3]int{-2, 0, 0}, Doors: [][3]int{{-2, 1, 0}}},
+					nil,
+				},
+			},
+			errors: []string{
+				"locks[1]: Must have at least one door",
+				"locks[2]: Cell [1 0 0] overlaps with locks[0]",
+				"locks[4]: Missing",
+				"locks: Must have at most 3 locks, found 5",
+			},
+		},
+		"Switches": {
+			puzzle: &perspectivefungo.Puzzle{
+				Size:   5,
+				Player: []int{0, 1, 0},
+				Goal:   []int{0, -1, 0},
+				Switches: []*perspectivefungo.GravitySwitch{
+					{Cell: [3]int{0, 0, 0}, Direction: [3]int{1, 0, 0}},
+					{Cell: [3]int{0, -1, 0}, Direction: [3]int{0, 0, -1}},
+					{Cell: [3]int{1, 0, 0}, Direction: [3]int{1, 1, 0}},
+					{Cell: [3]int{2, 0, 0}},
+					nil,
+				},
+			},
+			errors: []string{
+				"switches[1]: Cell [0 -1 0] overlaps with goal",
+				"switches[2]: Direction [1 1 0] is not an axis",
+				"switches[3]: Direction [0 0 0] is not an axis",
+				"switches[4]: Missing",
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := tt.puzzle.Validate()
+			assert.NotNil(t, err)
+			errs, ok := err.(perspectivefungo.ValidationErrors)
+			assert.True(t, ok)
+			var messages []string
+			for _, e := range errs {
+				messages = append(messages, e.Error())
+			}
+			assert.Equal(t, tt.errors, messages)
+		})
+	}
+}
+
+func TestPuzzleJSON(t *testing.T) {
+	t.Run("WithoutLinks", func(t *testing.T) {
+		data := `{"size":5,"player":[0,1,0],"goal":[0,-1,0],"blocks":[1,1,1],"portals":[-2,-2,-2,2,2,2]}`
+		var p perspectivefungo.Puzzle
+		assert.Nil(t, json.Unmarshal([]byte(data), &p))
+		assert.Nil(t, p.Links)
+		// Puzzles without links are written unchanged
+		encoded, err := json.Marshal(&p)
+		assert.Nil(t, err)
+		assert.Equal(t, data, string(encoded))
+	})
+	t.Run("WithLinks", func(t *testing.T) {
+		data := `{"size":5,"player":[0,1,0],"goal":[0,-1,0],"blocks":null,"portals":null,"links":[{"type":"one_way","from":[1,0,0],"to":[-1,0,0]}]}`
+		var p perspectivefungo.Puzzle
+		assert.Nil(t, json.Unmarshal([]byte(data), &p))
+		assert.Equal(t, []*perspectivefungo.Link{
+			{Type: perspectivefungo.ONE_WAY, From: [3]int{1, 0, 0}, To: [3]int{-1, 0, 0}},
+		}, p.Links)
+		encoded, err := json.Marshal(&p)
+		assert.Nil(t, err)
+		assert.Equal(t, data, string(encoded))
+	})
+	t.Run("Seed", func(t *testing.T) {
+		for _, seed := range []int64{0, 1234} {
+			p, err := perspectivefungo.Generate(seed, 5, 1, 0)
+			assert.Nil(t, err)
+			encoded, err := json.Marshal(p)
+			assert.Nil(t, err)
+			var decoded perspectivefungo.Puzzle
+			assert.Nil(t, json.Unmarshal(encoded, &decoded))
+			if assert.NotNil(t, decoded.Seed) {
+				assert.Equal(t, seed, *decoded.Seed)
+			}
+			assert.Equal(t, p, &decoded)
+		}
+	})
+}
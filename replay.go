@@ -0,0 +1,200 @@
+package perspectivefungo
+
+import (
+	"fmt"
+)
+
+const (
+	MIN_REPLAY_SPEED = 0.125
+	MAX_REPLAY_SPEED = 8
+)
+
+// Replay is a Game which plays back the events of a recorded solution instead of responding to the player.
+type Replay interface {
+	Game
+	Paused() bool
+	SetPaused(bool)
+	Step()
+	Speed() float64
+	SetSpeed(float64)
+}
+
+type replay struct {
+	*game
+	recording *Solution
+	next      int     // Index of the next event to apply
+	target    int     // Index of the event to stop after when stepping
+	stepping  bool    // True while stepping through a single event
+	clock     float64 // Seconds of the recording that have been played
+	last      float64 // Driver time of the previous frame
+	started   bool
+	paused    bool
+	speed     float64
+	settle    *Orientation // Recorded orientation to apply once the current animation completes
+}
+
+func NewReplay(puzzle *Puzzle, solution *Solution) (Replay, error) {
+	if solution.Version == 0 || len(solution.Events) == 0 {
+		return nil, fmt.Errorf("%w: no events to replay", ErrInvalidSolution)
+	}
+	r := &replay{
+		game:      NewGame(puzzle).(*game),
+		recording: solution,
+		speed:     1,
+	}
+	r.Reset()
+	return r, nil
+}
+
+func (r *replay) Init(d Driver) error {
+	if err := r.game.Init(d); err != nil {
+		return err
+	}
+	r.Reset()
+	return nil
+}
+
+func (r *replay) Reset() {
+	r.game.Reset()
+	r.next = 0
+	r.stepping = false
+	r.clock = 0
+	r.started = false
+	r.settle = nil
+}
+
+func (r *replay) Start() {
+	r.game.Start()
+}
+
+func (r *replay) Loop(d Driver) error {
+	now := d.Now()
+	if !r.started {
+		r.started = true
+		r.last = now
+		if !r.gameStarted {
+			r.Start()
+		}
+	}
+	elapsed := now - r.last
+	r.last = now
+	if !r.paused || r.stepping {
+		r.clock += elapsed * r.speed
+		r.dispatch()
+		r.update(r.clock)
+		if r.settle != nil && r.animation == nil {
+			// Follow the recording even if the animation ended elsewhere
+			r.rotation = r.settle.Mat4()
+			r.settle = nil
+		}
+		if r.stepping && r.next >= r.target && r.animation == nil {
+			r.stepping = false
+		}
+	}
+	if r.gameEnded && r.solution != nil && r.solution != r.recording {
+		// Show the recorded time rather than the time taken to replay
+		r.solution = r.recording
+	}
+	return r.draw(d)
+}
+
+// dispatch applies every event which is due, waiting for each animation to complete before applying the next event.
+func (r *replay) dispatch() {
+	for r.next < len(r.recording.Events) && r.animation == nil && !r.gameEnded {
+		if r.stepping && r.next >= r.target {
+			return
+		}
+		e := r.recording.Events[r.next]
+		offset := e.Time.Sub(r.recording.Start).Seconds()
+		if r.stepping && r.clock < offset {
+			// Skip ahead to the event being stepped to
+			r.clock = offset
+		}
+		if offset > r.clock {
+			return
+		}
+		r.next++
+		switch e.Type {
+		case ROTATE:
+			r.game.Rotate(e.X, e.Y)
+		case ROTATE_TO_AXIS:
+			r.game.RotateToAxis()
+			r.settleAt(e.Orientation)
+		case TURN:
+			r.game.Turn(int(e.X), int(e.Y))
+			r.settleAt(e.Orientation)
+		case RELEASE_BALL:
+			if o := e.Orientation; o != nil && o.Valid() {
+				// Remove any drift accumulated while replaying rotations
+				r.rotation = o.Mat4()
+			}
+			r.game.ReleaseBall()
+		case UNDO:
+			r.game.Undo()
+		case REDO:
+			r.game.Redo()
+		case HINT:
+			r.game.Hint()
+			r.settleAt(e.Orientation)
+		}
+	}
+}
+
+// settleAt applies the orientation once the animation completes, so changes to how turns and hints are calculated don't change older recordings.
+func (r *replay) settleAt(o *Orientation) {
+	if o != nil && o.Valid() {
+		r.settle = o
+	}
+}
+
+// Rotate is ignored as the replay controls the maze.
+func (r *replay) Rotate(float32, float32) {}
+
+// RotateToAxis is ignored as the replay controls the maze.
+func (r *replay) RotateToAxis() {}
+
+// Turn is ignored as the replay controls the maze.
+func (r *replay) Turn(int, int) {}
+
+// ReleaseBall is ignored as the replay controls the ball.
+func (r *replay) ReleaseBall() {}
+
+// Undo is ignored as the replay controls the ball.
+func (r *replay) Undo() {}
+
+// Redo is ignored as the replay controls the ball.
+func (r *replay) Redo() {}
+
+// Hint is ignored as the replay controls the maze.
+func (r *replay) Hint() {}
+
+func (r *replay) Paused() bool {
+	return r.paused
+}
+
+func (r *replay) SetPaused(paused bool) {
+	r.paused = paused
+	r.stepping = false
+}
+
+// Step pauses the replay, and plays the next event and its animation.
+func (r *replay) Step() {
+	r.paused = true
+	if r.next < len(r.recording.Events) {
+		r.stepping = true
+		r.target = r.next + 1
+	}
+}
+
+func (r *replay) Speed() float64 {
+	return r.speed
+}
+
+func (r *replay) SetSpeed(speed float64) {
+	if speed < MIN_REPLAY_SPEED {
+		speed = MIN_REPLAY_SPEED
+	} else if speed > MAX_REPLAY_SPEED {
+		speed = MAX_REPLAY_SPEED
+	}
+	r.speed = speed
+}
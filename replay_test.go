@@ -0,0 +1,147 @@
+package perspectivefungo_test
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"errors"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// clockDriver is a Driver which draws nothing, and whose time only advances when told.
+type clockDriver struct {
+	now float64
+}
+
+func (d *clockDriver) Init(g perspectivefungo.Game) error                { return g.Init(d) }
+func (d *clockDriver) Loop(now float64) error                            { return nil }
+func (d *clockDriver) AddMesh(string, int32, []float32, []float32) error { return nil }
+func (d *clockDriver) DrawMesh(string) error                             { return nil }
+func (d *clockDriver) Now() float64                                      { return d.now }
+func (d *clockDriver) SetProjection(*mgl32.Mat4)                         {}
+func (d *clockDriver) SetCamera(*mgl32.Mat4)                             {}
+func (d *clockDriver) SetModel(*mgl32.Mat4)                              {}
+func (d *clockDriver) SetLight(*mgl32.Vec3)                              {}
+func (d *clockDriver) SetColor(*mgl32.Vec4)                              {}
+
+// runSecond advances the driver by a second at 60 frames per second.
+func runSecond(t *testing.T, d *clockDriver, g perspectivefungo.Game) {
+	t.Helper()
+	for i := 0; i < 60; i++ {
+		d.now += 1. / 60
+		assert.Nil(t, g.Loop(d))
+	}
+}
+
+func TestReplay(t *testing.T) {
+	p := &perspectivefungo.Puzzle{
+		Size:   5,
+		Player: []int{0, 1, 0},
+		Goal:   []int{-2, -1, 0},
+		Blocks: []int{0, -2, 0},
+	}
+	start := time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+	identity := &perspectivefungo.Orientation{1, 0, 0, 0, 1, 0, 0, 0, 1}
+	rotated := &perspectivefungo.Orientation{0, -1, 0, 1, 0, 0, 0, 0, 1}
+	solution := &perspectivefungo.Solution{
+		Version: perspectivefungo.SOLUTION_VERSION,
+		Start:   start,
+		End:     at(5),
+		Events: []*perspectivefungo.Event{
+			{Time: at(1), Type: perspectivefungo.RELEASE_BALL, Orientation: identity},
+			{Time: at(2), Type: perspectivefungo.ROTATE, Y: 1.5},
+			{Time: at(3), Type: perspectivefungo.ROTATE_TO_AXIS, Orientation: rotated},
+			{Time: at(4), Type: perspectivefungo.RELEASE_BALL, Orientation: rotated},
+		},
+	}
+	// run advances the driver by the given number of frames at 60 frames per second
+	run := func(t *testing.T, d *clockDriver, r perspectivefungo.Replay, frames int) {
+		t.Helper()
+		for i := 0; i < frames; i++ {
+			d.now += 1. / 60
+			assert.Nil(t, r.Loop(d))
+		}
+	}
+	t.Run("Play", func(t *testing.T) {
+		d := &clockDriver{}
+		r, err := perspectivefungo.NewReplay(p, solution)
+		assert.Nil(t, err)
+		assert.Nil(t, d.Init(r))
+		run(t, d, r, 3*60)
+		assert.True(t, r.HasGameStarted())
+		assert.False(t, r.HasGameEnded())
+		run(t, d, r, 3*60)
+		assert.True(t, r.HasGameEnded())
+		assert.Equal(t, solution, r.Solution())
+	})
+	t.Run("Speed", func(t *testing.T) {
+		d := &clockDriver{}
+		r, err := perspectivefungo.NewReplay(p, solution)
+		assert.Nil(t, err)
+		assert.Nil(t, d.Init(r))
+		r.SetSpeed(4)
+		run(t, d, r, 2*60)
+		assert.True(t, r.HasGameEnded())
+		r.SetSpeed(1000)
+		assert.Equal(t, float64(perspectivefungo.MAX_REPLAY_SPEED), r.Speed())
+	})
+	t.Run("Pause", func(t *testing.T) {
+		d := &clockDriver{}
+		r, err := perspectivefungo.NewReplay(p, solution)
+		assert.Nil(t, err)
+		assert.Nil(t, d.Init(r))
+		r.SetPaused(true)
+		run(t, d, r, 10*60)
+		assert.False(t, r.HasGameEnded())
+		for i := 0; i < len(solution.Events); i++ {
+			r.Step()
+			run(t, d, r, 2*60)
+			assert.True(t, r.Paused())
+		}
+		assert.True(t, r.HasGameEnded())
+	})
+	t.Run("Rotations", func(t *testing.T) {
+		// Ignore input from the player
+		d := &clockDriver{}
+		r, err := perspectivefungo.NewReplay(p, solution)
+		assert.Nil(t, err)
+		assert.Nil(t, d.Init(r))
+		r.SetPaused(true)
+		run(t, d, r, 1)
+		r.ReleaseBall()
+		r.Rotate(1, 1)
+		r.SetPaused(false)
+		run(t, d, r, 6*60)
+		assert.True(t, r.HasGameEnded())
+		assert.Equal(t, solution, r.Solution())
+	})
+	t.Run("Turn", func(t *testing.T) {
+		// The recorded orientation is followed, even where it differs from the turn
+		d := &clockDriver{}
+		r, err := perspectivefungo.NewReplay(p, &perspectivefungo.Solution{
+			Version: perspectivefungo.SOLUTION_VERSION,
+			Start:   start,
+			End:     at(4),
+			Events: []*perspectivefungo.Event{
+				{Time: at(1), Type: perspectivefungo.RELEASE_BALL, Orientation: identity},
+				{Time: at(2), Type: perspectivefungo.TURN, Orientation: rotated},
+				{Time: at(3), Type: perspectivefungo.RELEASE_BALL},
+			},
+		})
+		assert.Nil(t, err)
+		assert.Nil(t, d.Init(r))
+		run(t, d, r, 5*60)
+		assert.True(t, r.HasGameEnded())
+	})
+	t.Run("Legacy", func(t *testing.T) {
+		_, err := perspectivefungo.NewReplay(p, &perspectivefungo.Solution{
+			Start: start,
+			End:   at(1),
+		})
+		assert.True(t, errors.Is(err, perspectivefungo.ErrInvalidSolution), err)
+	})
+}
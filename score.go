@@ -1,18 +1,19 @@
 package perspectivefungo
 
-const (
-	BAD  = -1
-	GOOD = 1
+import (
+	"errors"
 )
 
+var ErrUnsolvable = errors.New("Puzzle is unsolvable")
+
 var (
-	left       = []int{-1, 0, 0}
-	right      = []int{1, 0, 0}
-	down       = []int{0, -1, 0}
-	up         = []int{0, 1, 0}
-	backward   = []int{0, 0, -1}
-	foreward   = []int{0, 0, 1}
-	directions = [][]int{
+	left       = [3]int{-1, 0, 0}
+	right      = [3]int{1, 0, 0}
+	down       = [3]int{0, -1, 0}
+	up         = [3]int{0, 1, 0}
+	backward   = [3]int{0, 0, -1}
+	foreward   = [3]int{0, 0, 1}
+	directions = [][3]int{
 		left,
 		right,
 		down,
@@ -22,138 +23,212 @@ var (
 	}
 )
 
-func Score(puzzle *Puzzle) (uint, uint) {
-	blocks := make(map[string]bool)
-	for i := 0; i < len(puzzle.Blocks); i += 3 {
-		blocks[Key(puzzle.Blocks[i], puzzle.Blocks[i+1], puzzle.Blocks[i+2])] = true
+type Route struct {
+	Rotations uint    `json:"rotations"`
+	Penalties uint    `json:"penalties"`
+	Moves     []*Move `json:"moves"`
+}
+
+// Move orients the puzzle so gravity points in Direction, then releases the ball which comes to rest in Cell.
+type Move struct {
+	Direction [3]int   `json:"direction"`
+	Cell      [3]int   `json:"cell"`
+	Cells     [][3]int `json:"cells,omitempty"` // Where every ball comes to rest, only set when there is more than one ball, in which case Cell is the first
+}
+
+// newMove returns the move which made the fall.
+func newMove(f *Fall) *Move {
+	m := &Move{
+		Direction: f.Direction,
+		Cell:      f.State.Balls[0],
 	}
-	portals := make(map[string][]int)
-	var pair *[]int
-	for i := 0; i < len(puzzle.Portals); i += 3 {
-		x := puzzle.Portals[i]
-		y := puzzle.Portals[i+1]
-		z := puzzle.Portals[i+2]
-		if pair == nil {
-			pair = &[]int{x, y, z}
-		} else {
-			p := *pair
-			portals[Key(x, y, z)] = p
-			portals[Key(p[0], p[1], p[2])] = []int{x, y, z}
-			pair = nil
-		}
+	if len(f.State.Balls) > 1 {
+		m.Cells = f.State.Balls
 	}
-	tested := make(map[string]int)
-	visited := make(map[string]bool)
-	rotations, direction := ScoreDirections(puzzle.Size, puzzle.Player, puzzle.Goal, blocks, portals, tested, visited, false)
+	return m
+}
+
+// node is a state in the search, reached by a number of rotations.
+type node struct {
+	state       *State
+	orientation [3]int // The direction of gravity in the current orientation, which a switch doesn't change
+	rotations   uint
+	parent      *node
+	move        *Move
+}
+
+// nodeKey identifies a state and the direction of gravity, either that of a node or of a fall from its state.
+type nodeKey struct {
+	state     StateKey
+	direction [3]int
+}
+
+func (n *node) key() nodeKey {
+	return nodeKey{n.state.Key(), n.orientation}
+}
+
+// Score returns the fewest rotations needed to reach the goal, the penalty for elements of the puzzle that are never visited, and whether the goal can be reached at all, as a puzzle solved without rotating also scores zero rotations.
+func Score(puzzle *Puzzle) (uint, uint, bool) {
+	goal, penalty := search(NewMaze(puzzle), puzzle)
+	if goal == nil {
+		return 0, penalty, false
+	}
+	return goal.rotations, penalty, true
+}
+
+func Solve(puzzle *Puzzle) (*Route, error) {
+	goal, penalty := search(NewMaze(puzzle), puzzle)
+	if goal == nil {
+		return nil, ErrUnsolvable
+	}
+	route := &Route{
+		Rotations: goal.rotations,
+		Penalties: penalty,
+	}
+	for n := goal; n.parent != nil; n = n.parent {
+		route.Moves = append([]*Move{n.move}, route.Moves...)
+	}
+	return route, nil
+}
+
+// Hint returns the first move on the route with the fewest rotations from the given state, where gravity currently points in orientation.
+func Hint(maze *Maze, state *State, orientation [3]int) (*Move, error) {
+	goal := explore(maze, &node{
+		state:       state,
+		orientation: orientation,
+	}, make(map[[3]int]bool))
+	if goal == nil {
+		return nil, ErrUnsolvable
+	}
+	n := goal
+	for n.parent.parent != nil {
+		n = n.parent
+	}
+	return n.move, nil
+}
+
+// search explores every state reachable from the start of the maze, and returns the node which reached the goal with the fewest rotations, and the penalty for elements of the puzzle that were never visited.
+func search(maze *Maze, puzzle *Puzzle) (*node, uint) {
+	visited := make(map[[3]int]bool)
+	goal := explore(maze, &node{
+		state:       maze.Start(),
+		orientation: down,
+	}, visited)
 	penalty := uint(0)
 	// Check all blocks were visited
 	for i := 0; i < len(puzzle.Blocks); i += 3 {
-		if !visited[Key(puzzle.Blocks[i], puzzle.Blocks[i+1], puzzle.Blocks[i+2])] {
-			// log.Println("Unvisited Blocks: " + b.String())
+		if !visited[[3]int{puzzle.Blocks[i], puzzle.Blocks[i+1], puzzle.Blocks[i+2]}] {
+			penalty++
+		}
+	}
+	// Check all crumbling blocks were visited
+	for i := 0; i < len(puzzle.Crumbling); i += 3 {
+		if !visited[[3]int{puzzle.Crumbling[i], puzzle.Crumbling[i+1], puzzle.Crumbling[i+2]}] {
 			penalty++
 		}
 	}
 	// Check all portals were visited
 	for i := 0; i < len(puzzle.Portals); i += 3 {
-		if !visited[Key(puzzle.Portals[i], puzzle.Portals[i+1], puzzle.Portals[i+2])] {
-			// log.Println("Unvisited Portal: " + p.String())
+		if !visited[[3]int{puzzle.Portals[i], puzzle.Portals[i+1], puzzle.Portals[i+2]}] {
 			penalty++
 			penalty++ // Double penalty to encourage all portals to be visited
 		}
 	}
-	if rotations < 0 {
-		return 0, penalty
-	}
-	if direction[0] != down[0] || direction[1] != down[1] || direction[2] != down[2] {
-		// Add initial rotation
-		rotations += 1
+	// Check all links were visited
+	links := puzzle.linkCells()
+	for i := 0; i < len(links); i += 3 {
+		if !visited[[3]int{links[i], links[i+1], links[i+2]}] {
+			penalty++
+			penalty++
+		}
 	}
-	return uint(rotations), penalty
-}
-
-func ScoreDirections(size uint, player, goal []int, blocks map[string]bool, portals map[string][]int, tested map[string]int, visited map[string]bool, portaled bool) (int, []int) {
-	min := BAD
-	dir := down
-	posKey := Key(player[0], player[1], player[2])
-	for _, d := range directions {
-		key := posKey + Key(d[0], d[1], d[2])
-		rotations, ok := tested[key]
-		if !ok {
-			tested[key] = BAD // Set now, update later to avoid loops
-			rotations = ScoreDirection(size, []int{player[0], player[1], player[2]}, goal, blocks, portals, d, tested, visited, portaled)
-			tested[key] = rotations
+	// Check all keys were collected
+	for _, l := range puzzle.Locks {
+		if !visited[l.Key] {
+			penalty++
 		}
-		if rotations >= 0 && (rotations < min || min == BAD) {
-			min = rotations
-			dir = d
+	}
+	// Check all switches were visited
+	for _, s := range puzzle.Switches {
+		if !visited[s.Cell] {
+			penalty++
 		}
 	}
-	return min, dir
+	return goal, penalty
 }
 
-func ScoreDirection(size uint, player, goal []int, blocks map[string]bool, portals map[string][]int, direction []int, tested map[string]int, visited map[string]bool, portaled bool) int {
-	// log.Println("Scoring Direction:", direction)
-	rotations := 0
-	// Tracks portal usage to prevent infinite portal loops
-	usage := make(map[string]int)
-	for step := 0; ; step++ {
-		// log.Println("Player:", player)
-		if Abs(player[0]) > size || Abs(player[1]) > size || Abs(player[2]) > size {
-			// log.Println("Out of Bounds")
-			return BAD
-		}
-		if goal[0] == player[0] && goal[1] == player[1] && goal[2] == player[2] {
-			// log.Println("Goal")
-			return rotations
+// explore expands every node reachable from start, recording the blocks and portals used, and returns the node which reached the goal with the fewest rotations.
+func explore(maze *Maze, start *node, visited map[[3]int]bool) *node {
+	var goal *node
+	best := map[nodeKey]uint{
+		start.key(): 0,
+	}
+	falls := make(map[nodeKey]*Fall)
+	// Rotations cost either zero or one, so a double ended queue ensures nodes are expanded in order of rotations
+	queue := []*node{start}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		state := n.state.Key()
+		if n.rotations > best[nodeKey{state, n.orientation}] {
+			// A cheaper route to this node has already been expanded
+			continue
 		}
-		if step > 0 && !portaled {
-			key := Key(player[0], player[1], player[2])
-			link, ok := portals[key]
-			if ok {
-				uses, ok := usage[key]
-				if !ok {
-					uses = 0
+		for _, d := range directions {
+			key := nodeKey{state, d}
+			f, ok := falls[key]
+			if !ok {
+				f = maze.Release(n.state, d)
+				falls[key] = f
+				visit(f, visited)
+			}
+			rotations := n.rotations
+			if d != n.orientation {
+				rotations++
+			}
+			next := &node{
+				state:       f.State,
+				orientation: d,
+				rotations:   rotations,
+				parent:      n,
+				move:        newMove(f),
+			}
+			switch f.Outcome {
+			case GOAL:
+				if goal == nil || rotations < goal.rotations {
+					goal = next
 				}
-				if uses < 100 {
-					// log.Println("Portal")
-					player[0] = link[0]
-					player[1] = link[1]
-					player[2] = link[2]
-					portaled = true
-					usage[key] = uses + 1
-					visited[key] = true
-					visited[Key(link[0], link[1], link[2])] = true
+			case BLOCKED:
+				k := next.key()
+				if b, ok := best[k]; ok && b <= rotations {
 					continue
+				}
+				best[k] = rotations
+				if rotations == n.rotations {
+					queue = append([]*node{next}, queue...)
 				} else {
-					// log.Println("Infinite Portal Loop")
-					return BAD
+					queue = append(queue, next)
 				}
 			}
 		}
-		next := []int{
-			player[0] + direction[0],
-			player[1] + direction[1],
-			player[2] + direction[2],
-		}
-		if key := Key(next[0], next[1], next[2]); blocks[key] {
-			// log.Println("Blocks")
-			visited[key] = true
-			r, _ := ScoreDirections(size, player, goal, blocks, portals, tested, visited, portaled)
-			if r >= 0 {
-				return r + rotations + GOOD
-			}
-			return r
-		}
-		player[0] = next[0]
-		player[1] = next[1]
-		player[2] = next[2]
-		portaled = false
 	}
+	return goal
 }
 
-func Abs(a int) uint {
-	if a < 0 {
-		return uint(-a)
+// visit records the blocks, portals, switches, and keys used by the fall.
+func visit(f *Fall, visited map[[3]int]bool) {
+	for _, b := range f.falls() {
+		for i, s := range b.Path {
+			if s.Portal {
+				visited[b.Path[i-1].Cell] = true
+				visited[s.Cell] = true
+			}
+			if s.Switched || s.Collected {
+				visited[s.Cell] = true
+			}
+		}
+		if b.Outcome == BLOCKED {
+			visited[b.Block] = true
+		}
 	}
-	return uint(a)
 }
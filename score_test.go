@@ -0,0 +1,236 @@
+package perspectivefungo_test
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSolve(t *testing.T) {
+	t.Run("Direct", func(t *testing.T) {
+		p := &perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 1, 0},
+			Goal:   []int{0, -1, 0},
+		}
+		r, err := perspectivefungo.Solve(p)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(0), r.Rotations)
+		assert.Equal(t, uint(0), r.Penalties)
+		assert.Equal(t, []*perspectivefungo.Move{
+			{Direction: [3]int{0, -1, 0}, Cell: [3]int{0, -1, 0}},
+		}, r.Moves)
+		// Solved without rotating
+		rotations, penalties, solved := perspectivefungo.Score(p)
+		assert.Equal(t, uint(0), rotations)
+		assert.Equal(t, uint(0), penalties)
+		assert.True(t, solved)
+	})
+	t.Run("Rotation", func(t *testing.T) {
+		p := &perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 1, 0},
+			Goal:   []int{-2, 1, 0},
+		}
+		r, err := perspectivefungo.Solve(p)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(1), r.Rotations)
+		assert.Equal(t, []*perspectivefungo.Move{
+			{Direction: [3]int{-1, 0, 0}, Cell: [3]int{-2, 1, 0}},
+		}, r.Moves)
+	})
+	t.Run("Block", func(t *testing.T) {
+		p := &perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 2, 0},
+			Goal:   []int{2, 0, 0},
+			Blocks: []int{0, -1, 0},
+		}
+		r, err := perspectivefungo.Solve(p)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(1), r.Rotations)
+		assert.Equal(t, []*perspectivefungo.Move{
+			{Direction: [3]int{0, -1, 0}, Cell: [3]int{0, 0, 0}},
+			{Direction: [3]int{1, 0, 0}, Cell: [3]int{2, 0, 0}},
+		}, r.Moves)
+		rotations, penalties, _ := perspectivefungo.Score(p)
+		assert.Equal(t, rotations, r.Rotations)
+		assert.Equal(t, penalties, r.Penalties)
+	})
+	t.Run("Portal", func(t *testing.T) {
+		p := &perspectivefungo.Puzzle{
+			Size:    5,
+			Player:  []int{0, 1, 0},
+			Goal:    []int{1, -1, 0},
+			Portals: []int{0, -1, 0, 1, 1, 0},
+		}
+		r, err := perspectivefungo.Solve(p)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(0), r.Rotations)
+		assert.Equal(t, []*perspectivefungo.Move{
+			{Direction: [3]int{0, -1, 0}, Cell: [3]int{1, -1, 0}},
+		}, r.Moves)
+	})
+	t.Run("OneWay", func(t *testing.T) {
+		p := &perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 1, 0},
+			Goal:   []int{1, -1, 0},
+			Links: []*perspectivefungo.Link{
+				{Type: perspectivefungo.ONE_WAY, From: [3]int{0, -1, 0}, To: [3]int{1, 1, 0}},
+				{Type: perspectivefungo.ONE_WAY, From: [3]int{-2, -2, -2}, To: [3]int{2, 2, 2}},
+			},
+		}
+		r, err := perspectivefungo.Solve(p)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(0), r.Rotations)
+		// Both ends of the unused link are penalised
+		assert.Equal(t, uint(4), r.Penalties)
+		assert.Equal(t, []*perspectivefungo.Move{
+			{Direction: [3]int{0, -1, 0}, Cell: [3]int{1, -1, 0}},
+		}, r.Moves)
+	})
+	t.Run("Crumbling", func(t *testing.T) {
+		p := &perspectivefungo.Puzzle{
+			Size:      5,
+			Player:    []int{0, 2, 0},
+			Goal:      []int{0, -2, 0},
+			Crumbling: []int{0, 0, 0},
+		}
+		r, err := perspectivefungo.Solve(p)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(0), r.Rotations)
+		assert.Equal(t, uint(0), r.Penalties)
+		// Releasing from the same cell has a different outcome once the block has crumbled
+		assert.Equal(t, []*perspectivefungo.Move{
+			{Direction: [3]int{0, -1, 0}, Cell: [3]int{0, 1, 0}},
+			{Direction: [3]int{0, -1, 0}, Cell: [3]int{0, -2, 0}},
+		}, r.Moves)
+	})
+	t.Run("Lock", func(t *testing.T) {
+		p := &perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 2, 0},
+			Goal:   []int{0, -2, 0},
+			Blocks: []int{2, 2, 0, -1, 2, 0},
+			Locks: []*perspectivefungo.Lock{
+				{Key: [3]int{1, 2, 0}, Doors: [][3]int{{0, 0, 0}}},
+			},
+		}
+		r, err := perspectivefungo.Solve(p)
+		assert.Nil(t, err)
+		// The key has to be collected before the door can be passed
+		assert.Equal(t, uint(3), r.Rotations)
+		assert.Equal(t, uint(0), r.Penalties)
+		assert.Equal(t, []*perspectivefungo.Move{
+			{Direction: [3]int{1, 0, 0}, Cell: [3]int{1, 2, 0}},
+			{Direction: [3]int{-1, 0, 0}, Cell: [3]int{0, 2, 0}},
+			{Direction: [3]int{0, -1, 0}, Cell: [3]int{0, -2, 0}},
+		}, r.Moves)
+	})
+	t.Run("Switch", func(t *testing.T) {
+		p := &perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 2, 0},
+			Goal:   []int{2, 0, 0},
+			Switches: []*perspectivefungo.GravitySwitch{
+				{Cell: [3]int{0, 0, 0}, Direction: [3]int{1, 0, 0}},
+				{Cell: [3]int{-2, -2, -2}, Direction: [3]int{0, 1, 0}},
+			},
+		}
+		r, err := perspectivefungo.Solve(p)
+		assert.Nil(t, err)
+		// The switch turns the ball without rotating the puzzle
+		assert.Equal(t, uint(0), r.Rotations)
+		// The unused switch is penalised
+		assert.Equal(t, uint(1), r.Penalties)
+		assert.Equal(t, []*perspectivefungo.Move{
+			{Direction: [3]int{0, -1, 0}, Cell: [3]int{2, 0, 0}},
+		}, r.Moves)
+	})
+	t.Run("Balls", func(t *testing.T) {
+		p := &perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 2, 0, -2, 1, 0},
+			Goal:   []int{0, -2, 0},
+			Blocks: []int{1, 2, 0, 1, 1, 0},
+		}
+		r, err := perspectivefungo.Solve(p)
+		assert.Nil(t, err)
+		// The second ball has to be lined up with the first before both can fall into the shared goal
+		assert.Equal(t, uint(2), r.Rotations)
+		assert.Equal(t, uint(0), r.Penalties)
+		assert.Equal(t, []*perspectivefungo.Move{
+			{Direction: [3]int{1, 0, 0}, Cell: [3]int{0, 2, 0}, Cells: [][3]int{{0, 2, 0}, {0, 1, 0}}},
+			{Direction: [3]int{0, -1, 0}, Cell: [3]int{0, -2, 0}, Cells: [][3]int{{0, -2, 0}, {0, -2, 0}}},
+		}, r.Moves)
+	})
+	t.Run("Unsolvable", func(t *testing.T) {
+		p := &perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 1, 0},
+			Goal:   []int{1, -1, 0},
+		}
+		_, err := perspectivefungo.Solve(p)
+		assert.Equal(t, perspectivefungo.ErrUnsolvable, err)
+		rotations, _, solved := perspectivefungo.Score(p)
+		assert.Equal(t, uint(0), rotations)
+		assert.False(t, solved)
+	})
+	t.Run("JSON", func(t *testing.T) {
+		p := &perspectivefungo.Puzzle{
+			Size:   5,
+			Player: []int{0, 1, 0},
+			Goal:   []int{0, -1, 0},
+		}
+		r, err := perspectivefungo.Solve(p)
+		assert.Nil(t, err)
+		data, err := json.Marshal(r)
+		assert.Nil(t, err)
+		assert.Equal(t, `{"rotations":0,"penalties":0,"moves":[{"direction":[0,-1,0],"cell":[0,-1,0]}]}`, string(data))
+	})
+}
+
+func TestHint(t *testing.T) {
+	maze := perspectivefungo.NewMaze(&perspectivefungo.Puzzle{
+		Size:   5,
+		Player: []int{0, 2, 0},
+		Goal:   []int{2, 0, 0},
+		Blocks: []int{0, -1, 0},
+	})
+	t.Run("Start", func(t *testing.T) {
+		m, err := perspectivefungo.Hint(maze, maze.Start(), [3]int{0, -1, 0})
+		assert.Nil(t, err)
+		assert.Equal(t, &perspectivefungo.Move{Direction: [3]int{0, -1, 0}, Cell: [3]int{0, 0, 0}}, m)
+	})
+	t.Run("Progress", func(t *testing.T) {
+		f := maze.Release(maze.Start(), [3]int{0, -1, 0})
+		m, err := perspectivefungo.Hint(maze, f.State, [3]int{0, -1, 0})
+		assert.Nil(t, err)
+		assert.Equal(t, &perspectivefungo.Move{Direction: [3]int{1, 0, 0}, Cell: [3]int{2, 0, 0}}, m)
+	})
+	t.Run("Unsolvable", func(t *testing.T) {
+		f := maze.Release(maze.Start(), [3]int{1, 0, 0})
+		m, err := perspectivefungo.Hint(maze, f.State, [3]int{1, 0, 0})
+		assert.Nil(t, m)
+		assert.Equal(t, perspectivefungo.ErrUnsolvable, err)
+	})
+}
+
+func BenchmarkScore(b *testing.B) {
+	var puzzles []*perspectivefungo.Puzzle
+	for seed := int64(0); seed < 20; seed++ {
+		p, err := perspectivefungo.Generate(seed, 9, 40, 4)
+		if err != nil {
+			b.Fatal(err)
+		}
+		puzzles = append(puzzles, p)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, p := range puzzles {
+			perspectivefungo.Score(p)
+		}
+	}
+}
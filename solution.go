@@ -1,15 +1,313 @@
 package perspectivefungo
 
 import (
+	"errors"
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
 	"time"
 )
 
+// SOLUTION_VERSION is incremented whenever the encoding of a solution changes, solutions without a version only contain snapshots.
+const SOLUTION_VERSION = 2
+
+var ErrInvalidSolution = errors.New("Invalid Solution")
+
 type Solution struct {
-	Start, End time.Time
-	Progress   []*Snapshot
+	Version  uint        `json:"version"`
+	Start    time.Time   `json:"start"`
+	End      time.Time   `json:"end"`
+	Events   []*Event    `json:"events"`
+	Progress []*Snapshot `json:"progress"`
 }
 
 type Snapshot struct {
-	Time     time.Time
-	Position [3]float32
+	Time     time.Time  `json:"time"`
+	Position [3]float32 `json:"position"` // Of the first ball
+}
+
+type EventType string
+
+const (
+	ROTATE         EventType = "rotate"
+	ROTATE_TO_AXIS EventType = "rotate_to_axis"
+	RELEASE_BALL   EventType = "release_ball"
+	UNDO           EventType = "undo"
+	REDO           EventType = "redo"
+	HINT           EventType = "hint"
+	TURN           EventType = "turn"
+)
+
+// Event records a single input from the player.
+// Rotate events hold the total angles of a drag in radians, turn events hold the number of quarter turns as well as the orientation, while the other events hold the axis-aligned orientation that resulted.
+type Event struct {
+	Time        time.Time    `json:"time"`
+	Type        EventType    `json:"type"`
+	X           float32      `json:"x,omitempty"`
+	Y           float32      `json:"y,omitempty"`
+	Orientation *Orientation `json:"orientation,omitempty"`
+}
+
+// Orientation is an axis-aligned rotation stored as a row-major 3x3 matrix.
+type Orientation [9]int
+
+func NewOrientation(rotation mgl32.Mat4) *Orientation {
+	var o Orientation
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			o[r*3+c] = round(rotation.At(r, c))
+		}
+	}
+	return &o
+}
+
+// Mat4 returns the orientation as a rotation matrix.
+func (o *Orientation) Mat4() mgl32.Mat4 {
+	m := mgl32.Ident4()
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			m.Set(r, c, float32(o[r*3+c]))
+		}
+	}
+	return m
+}
+
+// Valid returns true if every row and column contains a single unit axis, and the orientation is a rotation rather than a reflection.
+func (o *Orientation) Valid() bool {
+	for i := 0; i < 3; i++ {
+		var row, column uint
+		for j := 0; j < 3; j++ {
+			row += Abs(o[i*3+j])
+			column += Abs(o[j*3+i])
+		}
+		if row != 1 || column != 1 {
+			return false
+		}
+	}
+	det := o[0]*(o[4]*o[8]-o[5]*o[7]) - o[1]*(o[3]*o[8]-o[5]*o[6]) + o[2]*(o[3]*o[7]-o[4]*o[6])
+	return det == 1
+}
+
+// Gravity returns the axis of the maze which points to the bottom of the screen.
+func (o *Orientation) Gravity() [3]int {
+	// The inverse of a rotation is its transpose, so gravity is the negated middle row
+	return [3]int{-o[3], -o[4], -o[5]}
+}
+
+// Nearest returns the orientation closest to rotation in which gravity points along the given axis of the maze.
+func Nearest(rotation mgl32.Mat4, gravity [3]int) *Orientation {
+	var (
+		nearest *Orientation
+		best    float32
+	)
+	for _, p := range [][3]int{{0, 1, 2}, {0, 2, 1}, {1, 0, 2}, {1, 2, 0}, {2, 0, 1}, {2, 1, 0}} {
+		for signs := 0; signs < 8; signs++ {
+			var o Orientation
+			for r := 0; r < 3; r++ {
+				if signs&(1<<r) == 0 {
+					o[r*3+p[r]] = 1
+				} else {
+					o[r*3+p[r]] = -1
+				}
+			}
+			if !o.Valid() || o.Gravity() != gravity {
+				continue
+			}
+			// The closer the orientations, the more their axes agree
+			var similarity float32
+			for r := 0; r < 3; r++ {
+				similarity += float32(o[r*3+p[r]]) * rotation.At(r, p[r])
+			}
+			if nearest == nil || similarity > best {
+				nearest = &o
+				best = similarity
+			}
+		}
+	}
+	return nearest
+}
+
+// Hints returns the number of hints which were shown.
+func (s *Solution) Hints() uint {
+	var hints uint
+	for _, e := range s.Events {
+		if e.Type == HINT {
+			hints++
+		}
+	}
+	return hints
+}
+
+// Undos returns the number of moves which were undone.
+func (s *Solution) Undos() uint {
+	var undos uint
+	for _, e := range s.Events {
+		if e.Type == UNDO {
+			undos++
+		}
+	}
+	return undos
+}
+
+// Verify replays the solution against the puzzle and returns an error unless it reaches the goal.
+func (s *Solution) Verify(p *Puzzle) error {
+	_, err := s.Replay(p)
+	return err
+}
+
+// Replay reconstructs the route taken by the solution, counting a rotation whenever the ball falls in a different direction to the previous fall.
+// Versioned solutions are replayed from their events, which must all fall between the start and end so the time taken can't be shorter than the play recorded, while older solutions are replayed from their snapshots.
+func (s *Solution) Replay(p *Puzzle) (*Route, error) {
+	if s.Version > SOLUTION_VERSION {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidSolution, s.Version)
+	}
+	if !s.End.After(s.Start) {
+		return nil, fmt.Errorf("%w: doesn't end after it starts", ErrInvalidSolution)
+	}
+	if s.Version == 0 {
+		return s.replaySnapshots(p)
+	}
+	return s.replayEvents(p)
+}
+
+func (s *Solution) replayEvents(p *Puzzle) (*Route, error) {
+	maze := NewMaze(p)
+	state := maze.Start()
+	orientation := down
+	route := &Route{}
+	solved := false
+	last := s.Start
+	// The route up to each move, so it can be undone and redone
+	type checkpoint struct {
+		state       *State
+		orientation [3]int
+		route       Route
+	}
+	var undos, redos []*checkpoint
+	save := func() *checkpoint {
+		return &checkpoint{
+			state:       state,
+			orientation: orientation,
+			route:       *route,
+		}
+	}
+	load := func(c *checkpoint) {
+		state = c.state
+		orientation = c.orientation
+		*route = c.route
+	}
+	for i, e := range s.Events {
+		if e.Time.Before(last) {
+			return nil, fmt.Errorf("%w: event %d is out of order", ErrInvalidSolution, i)
+		}
+		if e.Time.After(s.End) {
+			return nil, fmt.Errorf("%w: event %d is after the end", ErrInvalidSolution, i)
+		}
+		last = e.Time
+		switch e.Type {
+		case ROTATE:
+			// Free rotation doesn't affect the maze until it is snapped to an axis
+		case ROTATE_TO_AXIS, HINT, TURN:
+			if e.Orientation != nil && !e.Orientation.Valid() {
+				return nil, fmt.Errorf("%w: event %d has invalid orientation %v", ErrInvalidSolution, i, *e.Orientation)
+			}
+		case RELEASE_BALL:
+			if e.Orientation == nil || !e.Orientation.Valid() {
+				return nil, fmt.Errorf("%w: event %d has invalid orientation", ErrInvalidSolution, i)
+			}
+			if solved {
+				return nil, fmt.Errorf("%w: event %d moves after reaching the goal", ErrInvalidSolution, i)
+			}
+			undos = append(undos, save())
+			redos = nil
+			f := maze.Release(state, e.Orientation.Gravity())
+			if f.Outcome != BLOCKED && f.Outcome != GOAL {
+				return nil, fmt.Errorf("%w: event %d ends in %s", ErrInvalidSolution, i, f.Outcome)
+			}
+			if f.Direction != orientation {
+				orientation = f.Direction
+				route.Rotations++
+			}
+			// Copy so checkpoints don't share moves
+			route.Moves = append(append([]*Move{}, route.Moves...), newMove(f))
+			state = f.State
+			solved = f.Outcome == GOAL
+		case UNDO:
+			if len(undos) == 0 || solved {
+				return nil, fmt.Errorf("%w: event %d has nothing to undo", ErrInvalidSolution, i)
+			}
+			redos = append(redos, save())
+			load(undos[len(undos)-1])
+			undos = undos[:len(undos)-1]
+		case REDO:
+			if len(redos) == 0 || solved {
+				return nil, fmt.Errorf("%w: event %d has nothing to redo", ErrInvalidSolution, i)
+			}
+			undos = append(undos, save())
+			load(redos[len(redos)-1])
+			redos = redos[:len(redos)-1]
+		default:
+			return nil, fmt.Errorf("%w: event %d has unknown type %s", ErrInvalidSolution, i, e.Type)
+		}
+	}
+	if !solved {
+		return nil, fmt.Errorf("%w: goal not reached", ErrInvalidSolution)
+	}
+	return route, nil
+}
+
+func (s *Solution) replaySnapshots(p *Puzzle) (*Route, error) {
+	maze := NewMaze(p)
+	state := maze.Start()
+	orientation := down
+	route := &Route{}
+	solved := false
+	last := s.Start
+	for i, snapshot := range s.Progress {
+		if snapshot.Time.Before(last) {
+			return nil, fmt.Errorf("%w: snapshot %d is out of order", ErrInvalidSolution, i)
+		}
+		last = snapshot.Time
+		var position [3]int
+		for j, v := range snapshot.Position {
+			position[j] = round(v)
+		}
+		// Snapshots only record the first ball
+		if position == state.Balls[0] {
+			// Rotating doesn't move the ball
+			continue
+		}
+		if solved {
+			return nil, fmt.Errorf("%w: snapshot %d moves after reaching the goal", ErrInvalidSolution, i)
+		}
+		var next *Fall
+		// Prefer falling without rotating
+		for _, d := range append([][3]int{orientation}, directions...) {
+			f := maze.Release(state, d)
+			if (f.Outcome == BLOCKED || f.Outcome == GOAL) && f.State.Balls[0] == position {
+				next = f
+				break
+			}
+		}
+		if next == nil {
+			return nil, fmt.Errorf("%w: snapshot %d cannot reach %v from %v", ErrInvalidSolution, i, position, state.Balls[0])
+		}
+		if next.Direction != orientation {
+			orientation = next.Direction
+			route.Rotations++
+		}
+		route.Moves = append(route.Moves, newMove(next))
+		state = next.State
+		solved = next.Outcome == GOAL
+	}
+	if !solved {
+		return nil, fmt.Errorf("%w: goal not reached", ErrInvalidSolution)
+	}
+	return route, nil
+}
+
+func round(v float32) int {
+	if v < 0 {
+		return int(v - 0.5)
+	}
+	return int(v + 0.5)
 }
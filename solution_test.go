@@ -0,0 +1,279 @@
+package perspectivefungo_test
+
+import (
+	"aletheiaware.com/perspectivefungo"
+	"encoding/json"
+	"errors"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSolutionVerify(t *testing.T) {
+	p := &perspectivefungo.Puzzle{
+		Size:   5,
+		Player: []int{0, 1, 0},
+		Goal:   []int{-2, -1, 0},
+		Blocks: []int{0, -2, 0},
+	}
+	start := time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+	for name, tt := range map[string]struct {
+		solution *perspectivefungo.Solution
+		err      bool
+	}{
+		"Valid": {
+			solution: &perspectivefungo.Solution{
+				Start: start,
+				End:   at(3),
+				Progress: []*perspectivefungo.Snapshot{
+					{Time: at(1), Position: [3]float32{0, -1, 0}},
+					{Time: at(2), Position: [3]float32{0, -1, 0}},
+					{Time: at(3), Position: [3]float32{-2, -1, 0}},
+					{Time: at(4), Position: [3]float32{-2, -1, 0}},
+				},
+			},
+		},
+		"Unsolved": {
+			solution: &perspectivefungo.Solution{
+				Start: start,
+				End:   at(1),
+				Progress: []*perspectivefungo.Snapshot{
+					{Time: at(1), Position: [3]float32{0, -1, 0}},
+				},
+			},
+			err: true,
+		},
+		"Unreachable": {
+			solution: &perspectivefungo.Solution{
+				Start: start,
+				End:   at(1),
+				Progress: []*perspectivefungo.Snapshot{
+					{Time: at(1), Position: [3]float32{-2, -1, 0}},
+				},
+			},
+			err: true,
+		},
+		"Reversed": {
+			solution: &perspectivefungo.Solution{
+				Start: start,
+				End:   at(3),
+				Progress: []*perspectivefungo.Snapshot{
+					{Time: at(3), Position: [3]float32{0, -1, 0}},
+					{Time: at(1), Position: [3]float32{-2, -1, 0}},
+				},
+			},
+			err: true,
+		},
+		"EndsBeforeStart": {
+			solution: &perspectivefungo.Solution{
+				Start: at(3),
+				End:   start,
+				Progress: []*perspectivefungo.Snapshot{
+					{Time: at(4), Position: [3]float32{0, -1, 0}},
+					{Time: at(5), Position: [3]float32{-2, -1, 0}},
+				},
+			},
+			err: true,
+		},
+		"EndsAtStart": {
+			solution: &perspectivefungo.Solution{
+				Start: start,
+				End:   start,
+				Progress: []*perspectivefungo.Snapshot{
+					{Time: start, Position: [3]float32{0, -1, 0}},
+					{Time: start, Position: [3]float32{-2, -1, 0}},
+				},
+			},
+			err: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Round trip through JSON as the server would receive it
+			data, err := json.Marshal(tt.solution)
+			assert.Nil(t, err)
+			s := &perspectivefungo.Solution{}
+			assert.Nil(t, json.Unmarshal(data, s))
+			err = s.Verify(p)
+			if tt.err {
+				assert.True(t, errors.Is(err, perspectivefungo.ErrInvalidSolution), err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestSolutionReplay(t *testing.T) {
+	p := &perspectivefungo.Puzzle{
+		Size:   5,
+		Player: []int{0, 1, 0},
+		Goal:   []int{-2, -1, 0},
+		Blocks: []int{0, -2, 0},
+	}
+	start := time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)
+	s := &perspectivefungo.Solution{
+		Start: start,
+		End:   start.Add(2 * time.Second),
+		Progress: []*perspectivefungo.Snapshot{
+			{Time: start.Add(time.Second), Position: [3]float32{0, -1, 0}},
+			{Time: start.Add(2 * time.Second), Position: [3]float32{-2, -1, 0}},
+		},
+	}
+	r, err := s.Replay(p)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), r.Rotations)
+	assert.Equal(t, []*perspectivefungo.Move{
+		{Direction: [3]int{0, -1, 0}, Cell: [3]int{0, -1, 0}},
+		{Direction: [3]int{-1, 0, 0}, Cell: [3]int{-2, -1, 0}},
+	}, r.Moves)
+}
+
+func TestOrientation(t *testing.T) {
+	t.Run("Identity", func(t *testing.T) {
+		o := perspectivefungo.NewOrientation(mgl32.Ident4())
+		assert.Equal(t, &perspectivefungo.Orientation{1, 0, 0, 0, 1, 0, 0, 0, 1}, o)
+		assert.True(t, o.Valid())
+		assert.Equal(t, [3]int{0, -1, 0}, o.Gravity())
+	})
+	t.Run("Rotated", func(t *testing.T) {
+		r := mgl32.HomogRotate3D(mgl32.DegToRad(90), mgl32.Vec3{0, 0, 1})
+		o := perspectivefungo.NewOrientation(r)
+		assert.Equal(t, &perspectivefungo.Orientation{0, -1, 0, 1, 0, 0, 0, 0, 1}, o)
+		assert.True(t, o.Valid())
+		assert.Equal(t, perspectivefungo.Gravity(r), o.Gravity())
+	})
+	t.Run("Reflected", func(t *testing.T) {
+		o := &perspectivefungo.Orientation{-1, 0, 0, 0, 1, 0, 0, 0, 1}
+		assert.False(t, o.Valid())
+	})
+	t.Run("Skewed", func(t *testing.T) {
+		o := &perspectivefungo.Orientation{1, 0, 0, 1, 0, 0, 0, 0, 1}
+		assert.False(t, o.Valid())
+	})
+	t.Run("Nearest", func(t *testing.T) {
+		o := perspectivefungo.Nearest(mgl32.Ident4(), [3]int{0, -1, 0})
+		assert.Equal(t, &perspectivefungo.Orientation{1, 0, 0, 0, 1, 0, 0, 0, 1}, o)
+		o = perspectivefungo.Nearest(mgl32.Ident4(), [3]int{-1, 0, 0})
+		assert.Equal(t, &perspectivefungo.Orientation{0, -1, 0, 1, 0, 0, 0, 0, 1}, o)
+		// Every direction of gravity has an orientation
+		for _, g := range [][3]int{{1, 0, 0}, {0, 1, 0}, {0, 0, -1}, {0, 0, 1}} {
+			o = perspectivefungo.Nearest(mgl32.HomogRotate3D(0.3, mgl32.Vec3{1, 1, 0}.Normalize()), g)
+			assert.True(t, o.Valid())
+			assert.Equal(t, g, o.Gravity())
+		}
+	})
+}
+
+func TestSolutionEvents(t *testing.T) {
+	p := &perspectivefungo.Puzzle{
+		Size:   5,
+		Player: []int{0, 1, 0},
+		Goal:   []int{-2, -1, 0},
+		Blocks: []int{0, -2, 0},
+	}
+	start := time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+	identity := &perspectivefungo.Orientation{1, 0, 0, 0, 1, 0, 0, 0, 1}
+	rotated := &perspectivefungo.Orientation{0, -1, 0, 1, 0, 0, 0, 0, 1}
+	t.Run("Valid", func(t *testing.T) {
+		s := &perspectivefungo.Solution{
+			Version: perspectivefungo.SOLUTION_VERSION,
+			Start:   start,
+			End:     at(4),
+			Events: []*perspectivefungo.Event{
+				{Time: at(1), Type: perspectivefungo.RELEASE_BALL, Orientation: identity},
+				{Time: at(2), Type: perspectivefungo.ROTATE, Y: 0.5},
+				{Time: at(2), Type: perspectivefungo.ROTATE_TO_AXIS, Orientation: rotated},
+				{Time: at(3), Type: perspectivefungo.RELEASE_BALL, Orientation: rotated},
+			},
+		}
+		r, err := s.Replay(p)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(1), r.Rotations)
+		assert.Equal(t, []*perspectivefungo.Move{
+			{Direction: [3]int{0, -1, 0}, Cell: [3]int{0, -1, 0}},
+			{Direction: [3]int{-1, 0, 0}, Cell: [3]int{-2, -1, 0}},
+		}, r.Moves)
+	})
+	t.Run("Unsolved", func(t *testing.T) {
+		s := &perspectivefungo.Solution{
+			Version: perspectivefungo.SOLUTION_VERSION,
+			Start:   start,
+			End:     at(1),
+			Events: []*perspectivefungo.Event{
+				{Time: at(1), Type: perspectivefungo.RELEASE_BALL, Orientation: identity},
+			},
+		}
+		_, err := s.Replay(p)
+		assert.True(t, errors.Is(err, perspectivefungo.ErrInvalidSolution), err)
+	})
+	t.Run("AfterEnd", func(t *testing.T) {
+		s := &perspectivefungo.Solution{
+			Version: perspectivefungo.SOLUTION_VERSION,
+			Start:   start,
+			End:     at(1),
+			Events: []*perspectivefungo.Event{
+				{Time: at(1), Type: perspectivefungo.RELEASE_BALL, Orientation: identity},
+				{Time: at(3), Type: perspectivefungo.RELEASE_BALL, Orientation: rotated},
+			},
+		}
+		_, err := s.Replay(p)
+		assert.True(t, errors.Is(err, perspectivefungo.ErrInvalidSolution), err)
+	})
+	t.Run("OutOfBounds", func(t *testing.T) {
+		s := &perspectivefungo.Solution{
+			Version: perspectivefungo.SOLUTION_VERSION,
+			Start:   start,
+			End:     at(1),
+			Events: []*perspectivefungo.Event{
+				{Time: at(1), Type: perspectivefungo.RELEASE_BALL, Orientation: &perspectivefungo.Orientation{1, 0, 0, 0, -1, 0, 0, 0, -1}},
+			},
+		}
+		_, err := s.Replay(p)
+		assert.True(t, errors.Is(err, perspectivefungo.ErrInvalidSolution), err)
+	})
+	t.Run("MissingOrientation", func(t *testing.T) {
+		s := &perspectivefungo.Solution{
+			Version: perspectivefungo.SOLUTION_VERSION,
+			Start:   start,
+			End:     at(1),
+			Events: []*perspectivefungo.Event{
+				{Time: at(1), Type: perspectivefungo.RELEASE_BALL},
+			},
+		}
+		_, err := s.Replay(p)
+		assert.True(t, errors.Is(err, perspectivefungo.ErrInvalidSolution), err)
+	})
+	t.Run("UnsupportedVersion", func(t *testing.T) {
+		s := &perspectivefungo.Solution{
+			Version: perspectivefungo.SOLUTION_VERSION + 1,
+			Start:   start,
+			End:     at(1),
+		}
+		_, err := s.Replay(p)
+		assert.True(t, errors.Is(err, perspectivefungo.ErrInvalidSolution), err)
+	})
+	t.Run("Encoding", func(t *testing.T) {
+		data, err := json.Marshal(&perspectivefungo.Solution{
+			Version: perspectivefungo.SOLUTION_VERSION,
+			Start:   start,
+			End:     at(3),
+			Events: []*perspectivefungo.Event{
+				{Time: at(1), Type: perspectivefungo.ROTATE, X: 0.25, Y: -0.5},
+				{Time: at(2), Type: perspectivefungo.ROTATE_TO_AXIS, Orientation: identity},
+				{Time: at(3), Type: perspectivefungo.RELEASE_BALL, Orientation: identity},
+			},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, `{"version":2,"start":"2026-11-01T12:00:00Z","end":"2026-11-01T12:00:03Z","events":[`+
+			`{"time":"2026-11-01T12:00:01Z","type":"rotate","x":0.25,"y":-0.5},`+
+			`{"time":"2026-11-01T12:00:02Z","type":"rotate_to_axis","orientation":[1,0,0,0,1,0,0,0,1]},`+
+			`{"time":"2026-11-01T12:00:03Z","type":"release_ball","orientation":[1,0,0,0,1,0,0,0,1]}],"progress":null}`, string(data))
+	})
+}